@@ -10,6 +10,9 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos returns where the node starts in the source code, which for
+	// operators, calls and indexing is where their left operand starts
+	Pos() token.Position
 }
 
 // All statement nodes implement this
@@ -41,6 +44,15 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+// Pos returns the position of the first statement.
+// If the list of Statements is empty, it returns the zero Position
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 // String returns the aggregated value of the String method
 // of each statement
 func (p *Program) String() string {
@@ -55,18 +67,20 @@ func (p *Program) String() string {
 
 // Statements
 type LetStatement struct {
-	Token token.Token // the token.LET token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // the token.LET token
+	Name    *Identifier
+	Pattern Pattern // what a destructuring let binds, in place of Name
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(ls.Target().String())
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -78,6 +92,102 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// Target returns what the statement binds: its Name, or its Pattern when
+// it destructures
+func (ls *LetStatement) Target() Node {
+	if ls.Pattern != nil {
+		return ls.Pattern
+	}
+	return ls.Name
+}
+
+// ConstStatement binds a name to a value that cannot be reassigned
+// const max = 10;
+type ConstStatement struct {
+	Token token.Token // the token.CONST token
+	Name  *Identifier
+	Value Expression
+}
+
+var _ Statement = (*ConstStatement)(nil)
+
+func (cs *ConstStatement) statementNode()       {}
+func (cs *ConstStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ConstStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ConstStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(cs.TokenLiteral() + " ")
+	out.WriteString(cs.Name.String())
+	out.WriteString(" = ")
+
+	if cs.Value != nil {
+		out.WriteString(cs.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// Pattern is the target of a destructuring let statement
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// ArrayPattern binds the elements of an array to names, in order.
+// let [a, b, ...rest] = arr;
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []*Identifier
+	Rest     *Identifier // bound to the remaining elements, if any
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) String() string {
+	names := []string{}
+	for _, el := range ap.Elements {
+		names = append(names, el.String())
+	}
+	if ap.Rest != nil {
+		names = append(names, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// HashPattern binds values of a hash to names, looking each one up by a
+// string key. The key doubles as the name unless the entry renames it.
+// let {name, age: years} = person;
+type HashPattern struct {
+	Token   token.Token // the '{' token
+	Entries []*HashPatternEntry
+}
+
+// HashPatternEntry is a single key of a HashPattern with the name its
+// value is bound to
+type HashPatternEntry struct {
+	Key  *Identifier // spells the string key to look up
+	Name *Identifier
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) String() string {
+	entries := []string{}
+	for _, e := range hp.Entries {
+		if e.Key.Value == e.Name.Value {
+			entries = append(entries, e.Name.String())
+			continue
+		}
+		entries = append(entries, e.Key.String()+": "+e.Name.String())
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
 type ReturnStatement struct {
 	Token       token.Token // the 'return' token
 	ReturnValue Expression
@@ -85,6 +195,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -99,6 +210,30 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// BreakStatement exits the innermost enclosing loop
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+var _ Statement = (*BreakStatement)(nil)
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) String() string       { return bs.TokenLiteral() + ";" }
+
+// ContinueStatement skips to the next iteration of the innermost enclosing loop
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+var _ Statement = (*ContinueStatement)(nil)
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) String() string       { return cs.TokenLiteral() + ";" }
+
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
 	Expression Expression
@@ -106,6 +241,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -120,6 +256,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -138,6 +275,7 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
 func (i *Identifier) String() string       { return i.Value }
 
 type Boolean struct {
@@ -147,6 +285,7 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IntegerLiteral struct {
@@ -156,6 +295,7 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
 type PrefixExpression struct {
@@ -166,6 +306,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -177,6 +318,31 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// PostfixExpression applies an operator placed after its operand, e.g. x++
+type PostfixExpression struct {
+	Token    token.Token // The postfix token, e.g. ++
+	Left     Expression
+	Operator string
+}
+
+var _ Expression = (*PostfixExpression)(nil)
+
+func (pe *PostfixExpression) expressionNode()      {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) Pos() token.Position {
+	return pe.Left.Pos()
+}
+func (pe *PostfixExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(pe.Operator)
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type InfixExpression struct {
 	Token    token.Token // The operator token, e.g. +
 	Left     Expression
@@ -186,6 +352,9 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -198,6 +367,62 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression rebinds an existing name to a new value
+// <identifier> = <expression>
+type AssignExpression struct {
+	Token token.Token // the '=' token
+	Name  *Identifier
+	Value Expression
+}
+
+var _ Expression = (*AssignExpression)(nil)
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Name.Pos()
+}
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ae.Name.String())
+	out.WriteString(" = ")
+	out.WriteString(ae.Value.String())
+
+	return out.String()
+}
+
+// TernaryExpression picks one of two expressions depending on a condition
+// <condition> ? <consequence> : <alternative>
+type TernaryExpression struct {
+	Token       token.Token // the '?' token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+var _ Expression = (*TernaryExpression)(nil)
+
+func (te *TernaryExpression) expressionNode()      {}
+func (te *TernaryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TernaryExpression) Pos() token.Position {
+	// the expression starts with its condition
+	return te.Condition.Pos()
+}
+func (te *TernaryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(te.Condition.String())
+	out.WriteString(" ? ")
+	out.WriteString(te.Consequence.String())
+	out.WriteString(" : ")
+	out.WriteString(te.Alternative.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type IfExpression struct {
 	Token       token.Token // The 'if' token
 	Condition   Expression
@@ -207,6 +432,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -223,27 +449,199 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// SwitchExpression runs the body of the first case whose value equals its
+// subject, or its default body if none does
+// switch (<subject>) { case <value> { <body> } default { <body> } }
+type SwitchExpression struct {
+	Token   token.Token // The 'switch' token
+	Subject Expression
+	Cases   []*SwitchCase
+	Default *BlockStatement // nil without a default case
+}
+
+// SwitchCase is a single case of a SwitchExpression
+type SwitchCase struct {
+	Token token.Token // The 'case' token
+	Value Expression
+	Body  *BlockStatement
+}
+
+var _ Expression = (*SwitchExpression)(nil)
+
+func (se *SwitchExpression) expressionNode()      {}
+func (se *SwitchExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SwitchExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SwitchExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("switch")
+	out.WriteString(se.Subject.String())
+	out.WriteString(" ")
+
+	for _, c := range se.Cases {
+		out.WriteString("case ")
+		out.WriteString(c.Value.String())
+		out.WriteString(" ")
+		out.WriteString(c.Body.String())
+	}
+
+	if se.Default != nil {
+		out.WriteString("default ")
+		out.WriteString(se.Default.String())
+	}
+
+	return out.String()
+}
+
+// WhileExpression evaluates its body for as long as its condition is truthy
+// while (<condition>) { <body> }
+type WhileExpression struct {
+	Token     token.Token // The 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+var _ Expression = (*WhileExpression)(nil)
+
+func (we *WhileExpression) expressionNode()      {}
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WhileExpression) Pos() token.Position  { return we.Token.Pos }
+func (we *WhileExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(we.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(we.Body.String())
+
+	return out.String()
+}
+
+// DoWhileExpression evaluates its body once, then again for as long as its
+// condition is truthy
+// do { <body> } while (<condition>)
+type DoWhileExpression struct {
+	Token     token.Token // The 'do' token
+	Body      *BlockStatement
+	Condition Expression
+}
+
+var _ Expression = (*DoWhileExpression)(nil)
+
+func (dw *DoWhileExpression) expressionNode()      {}
+func (dw *DoWhileExpression) TokenLiteral() string { return dw.Token.Literal }
+func (dw *DoWhileExpression) Pos() token.Position  { return dw.Token.Pos }
+func (dw *DoWhileExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("do ")
+	out.WriteString(dw.Body.String())
+	out.WriteString(" while")
+	out.WriteString(dw.Condition.String())
+
+	return out.String()
+}
+
+// ForExpression runs its init statement once, then evaluates its body for
+// as long as its condition is truthy, running its update after each pass.
+// Any of init, condition and update may be left out
+// for (<init>; <condition>; <update>) { <body> }
+type ForExpression struct {
+	Token     token.Token // The 'for' token
+	Init      Statement   // nil when omitted
+	Condition Expression  // nil when omitted, looping until a break
+	Update    Expression  // nil when omitted
+	Body      *BlockStatement
+}
+
+var _ Expression = (*ForExpression)(nil)
+
+func (fe *ForExpression) expressionNode()      {}
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForExpression) Pos() token.Position  { return fe.Token.Pos }
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fe.Init != nil {
+		out.WriteString(strings.TrimSuffix(fe.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fe.Condition != nil {
+		out.WriteString(fe.Condition.String())
+	}
+	out.WriteString("; ")
+	if fe.Update != nil {
+		out.WriteString(fe.Update.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+
+	return out.String()
+}
+
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
+	Defaults   map[string]Expression // default values of optional parameters, by name
 	Body       *BlockStatement
 }
 
+// ParameterList returns each parameter as written in a function literal,
+// followed by its default value if it has one
+func ParameterList(parameters []*Identifier, defaults map[string]Expression) []string {
+	params := make([]string, 0, len(parameters))
+	for _, p := range parameters {
+		if def, ok := defaults[p.Value]; ok {
+			params = append(params, p.Value+" = "+def.String())
+			continue
+		}
+		params = append(params, p.Value)
+	}
+	return params
+}
+
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(ParameterList(fl.Parameters, fl.Defaults), ", "))
+	out.WriteString(") ")
+	out.WriteString(fl.Body.String())
+
+	return out.String()
+}
+
+// MacroLiteral defines a macro. It is only meaningful as the value of a
+// top level let statement, which binds it before evaluation
+type MacroLiteral struct {
+	Token      token.Token // The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+var _ Expression = (*MacroLiteral)(nil)
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
 	params := []string{}
-	for _, p := range fl.Parameters {
+	for _, p := range ml.Parameters {
 		params = append(params, p.String())
 	}
 
-	out.WriteString(fl.TokenLiteral())
+	out.WriteString(ml.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
+	out.WriteString(ml.Body.String())
 
 	return out.String()
 }
@@ -256,6 +654,9 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Function.Pos()
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -282,8 +683,22 @@ var _ Expression = (*StringLiteral)(nil)
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// CharLiteral represents a single quoted character, e.g. 'a'
+type CharLiteral struct {
+	Token token.Token // the literal holds the text between the quotes
+	Value rune
+}
+
+var _ Expression = (*CharLiteral)(nil)
+
+func (cl *CharLiteral) expressionNode()      {}
+func (cl *CharLiteral) TokenLiteral() string { return cl.Token.Literal }
+func (cl *CharLiteral) Pos() token.Position  { return cl.Token.Pos }
+func (cl *CharLiteral) String() string       { return "'" + cl.Token.Literal + "'" }
+
 // ArrayLiteral is an expression representing an array in monkey language
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
@@ -294,6 +709,7 @@ var _ Expression = (*ArrayLiteral)(nil)
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -319,6 +735,9 @@ var _ Expression = (*IndexExpression)(nil)
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -331,6 +750,40 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is an expression for taking part of an array or a string
+// <expression>[<low>:<high>], where either bound may be omitted
+type SliceExpression struct {
+	Token token.Token // The [ token
+	Left  Expression
+	Low   Expression // nil when omitted
+	High  Expression // nil when omitted
+}
+
+var _ Expression = (*SliceExpression)(nil)
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position {
+	return se.Left.Pos()
+}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 // HashLiteral
 // {<expression> : <expression>, <expression> : <expression>, ... }
 type HashLiteral struct {
@@ -342,6 +795,7 @@ var _ Expression = (*HashLiteral)(nil)
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
@@ -1,7 +1,12 @@
 package ast_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
 	"monkey/token"
 	"testing"
 )
@@ -28,3 +33,272 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String returned %q, expected %q", got, want)
 	}
 }
+
+func TestParameterList(t *testing.T) {
+	program := parser.New(lexer.New("fn(a, b = 2, c = a + 1) { a }")).ParseProgram()
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+
+	want := "[a b = 2 c = (a + 1)]"
+	if got := fmt.Sprint(ast.ParameterList(fn.Parameters, fn.Defaults)); got != want {
+		t.Errorf("wrong parameter list. want=%q, got=%q", want, got)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	input := `
+let x = 5;
+let add = fn(x, b) { x + b };
+add(x, [x, 1][0]);
+if (x > 1) { x } else { {"x": x}["x"] };
+`
+	want := "let y = 5;" +
+		"let add = fn(y, b) (y + b);" +
+		"add(y, ([y, 1][0]))" +
+		"if(y > 1) yelse ({x:y}[x])"
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	rename := func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok || ident.Value != "x" {
+			return node
+		}
+		return &ast.Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: "y"},
+			Value: "y",
+		}
+	}
+
+	got := ast.Transform(program, rename).String()
+	if got != want {
+		t.Errorf("transformed program.String returned %q, expected %q", got, want)
+	}
+}
+
+func TestModify(t *testing.T) {
+	input := `
+let x = 1;
+fn(a) { a * 2 };
+[1, 2][0];
+{"one": 1};
+if (3 > 4) { 5 } else { 6 };
+return -7;
+`
+	want := "let x = 2;" +
+		"fn(a) (a * 4)" +
+		"([2, 4][0])" +
+		"{one:2}" +
+		"if(6 > 8) 10else 12" +
+		"return (-14);"
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	double := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok {
+			return node
+		}
+		integer.Value *= 2
+		integer.Token.Literal = fmt.Sprint(integer.Value)
+		return integer
+	}
+
+	got := ast.Modify(program, double).String()
+	if got != want {
+		t.Errorf("modified program.String returned %q, expected %q", got, want)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	input := `
+let x = 5;
+let add = fn(a, b) { a + b };
+add(x, [x, 1][0]);
+if (x > 1) { x } else { {"x": x}["x"] };
+`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	count := 0
+	ast.Walk(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.Identifier); ok {
+			count++
+		}
+		return true
+	})
+
+	// x, add, a, b, a, b, add, x, x, x, x, x
+	if count != 12 {
+		t.Errorf("Walk visited %d identifiers, expected 12", count)
+	}
+}
+
+func TestWalkSkipsSubtree(t *testing.T) {
+	program := parser.New(lexer.New("let f = fn(a) { a }; f(1);")).ParseProgram()
+
+	count := 0
+	ast.Walk(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.FunctionLiteral); ok {
+			return false
+		}
+		if _, ok := node.(*ast.Identifier); ok {
+			count++
+		}
+		return true
+	})
+
+	// f, f
+	if count != 2 {
+		t.Errorf("Walk visited %d identifiers, expected 2", count)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	program := parser.New(lexer.New(`let x = 5; x + 1; {"a": [true]}`)).ParseProgram()
+
+	got, err := json.Marshal(program)
+	if err != nil {
+		t.Fatalf("json.Marshal returned an error: %s", err)
+	}
+
+	want := `{"statements":[` +
+		`{"name":{"type":"Identifier","value":"x"},"type":"LetStatement","value":{"type":"IntegerLiteral","value":5}},` +
+		`{"expression":{"left":{"type":"Identifier","value":"x"},"operator":"+","right":{"type":"IntegerLiteral","value":1},"type":"InfixExpression"},"type":"ExpressionStatement"},` +
+		`{"expression":{"pairs":[{"key":{"type":"StringLiteral","value":"a"},"value":{"elements":[{"type":"Boolean","value":true}],"type":"ArrayLiteral"}}],"type":"HashLiteral"},"type":"ExpressionStatement"}` +
+		`],"type":"Program"}`
+	if string(got) != want {
+		t.Errorf("json.Marshal returned\n%s\nexpected\n%s", got, want)
+	}
+}
+
+func TestPrettyPrint(t *testing.T) {
+	program := parser.New(lexer.New("1 + 2 * 3")).ParseProgram()
+
+	want, err := ioutil.ReadFile("testdata/pretty_print.golden")
+	if err != nil {
+		t.Fatalf("could not read golden file: %s", err)
+	}
+
+	got := ast.PrettyPrint(program)
+	if got != string(want) {
+		t.Errorf("PrettyPrint returned\n%s\nexpected\n%s", got, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	input := `let max=fn(a,b){if(a>b){return a;}else{b}};
+let fib = fn(n) { if (n < 2) { n } else { fib(n-1) + fib(n - 2) } };
+let sign = fn(n) { if (n < 0) { -1 } else if (n > 0) { 1 } else { 0 } };
+let name = fn(n) { switch (n) { case 1 { "one" } case 2 {} default { "many" } } };
+let counter=fn(){let i=0;while(i<3){i++;if(i==2){continue;}puts(i*(2+3),-(-i))}};
+x = y = (1 - (2 - 3)) * 4; max(1, 2)[0];
+let pick = fn(a) { (a > 1 ? a : 0) ? "big" : a < 0 ? "neg" : "small" };
+let [first,...others]=xs;
+let {name,age:years}=person;
+do{i++}while(i<3);
+for(let i=0;i<3;i++){if(i==1){continue}puts(i)}
+for(;;){break}
+const limit=10*2
+let ok=(a||b)&&!c||d`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	want, err := ioutil.ReadFile("testdata/format.golden")
+	if err != nil {
+		t.Fatalf("could not read golden file: %s", err)
+	}
+
+	got := ast.Format(program)
+	if got != string(want) {
+		t.Errorf("Format returned\n%s\nexpected\n%s", got, want)
+	}
+
+	reparsed := parser.New(lexer.New(got)).ParseProgram()
+	if reparsed.String() != program.String() {
+		t.Errorf("formatted program parses to %q, expected %q",
+			reparsed.String(), program.String())
+	}
+}
+
+func TestPos(t *testing.T) {
+	input := `let add = fn(a, b) {
+  return a +
+    b;
+};`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	var positions []token.Position
+	ast.Walk(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok && ident.Value == "b" {
+			positions = append(positions, node.Pos())
+		}
+		return true
+	})
+
+	want := []token.Position{{Line: 1, Column: 17}, {Line: 3, Column: 5}}
+	if len(positions) != len(want) {
+		t.Fatalf("found %d identifiers named b, expected %d", len(positions), len(want))
+	}
+	for i, pos := range positions {
+		if pos != want[i] {
+			t.Errorf("positions[%d] is %s, expected %s", i, pos, want[i])
+		}
+	}
+
+	if got := program.Pos(); got != (token.Position{Line: 1, Column: 1}) {
+		t.Errorf("program.Pos() is %s, expected 1:1", got)
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 + 3", "5"},
+		{"2 + 3 * 4 - 1", "13"},
+		{"10 / 2", "5"},
+		{"-5 + 1", "-4"},
+		{"!true", "false"},
+		{"!!false", "false"},
+		{"~5 + 1", "-5"},
+		{"1 < 2 == true", "true"},
+		{"let a = (1 + 2) * 3;", "let a = 9;"},
+		{"fn(x) { x + (1 + 1) }", "fn(x) (x + 2)"},
+		// non-foldable expressions pass through unchanged
+		{"x + 1", "(x + 1)"},
+		{"1 + x * 2", "(1 + (x * 2))"},
+		{"f(1) + 2", "(f(1) + 2)"},
+		{"1 / 0", "(1 / 0)"},
+		{"1 % 0", "(1 % 0)"},
+		{"7 % 4", "3"},
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1)"},
+		{"9223372036854775807 * 2", "(9223372036854775807 * 2)"},
+		{`"a" + "b"`, "(a + b)"},
+		{"1 + true", "(1 + true)"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		got := ast.Fold(program).String()
+		if got != tt.expected {
+			t.Errorf("Fold(%q) returned %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFoldLiteralTypes(t *testing.T) {
+	program := parser.New(lexer.New("2 * 3; 1 > 2;")).ParseProgram()
+	folded := ast.Fold(program).(*ast.Program)
+
+	integer, ok := folded.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	if !ok || integer.Value != 6 {
+		t.Errorf("first statement not folded to IntegerLiteral 6. got=%#v",
+			folded.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+
+	boolean, ok := folded.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.Boolean)
+	if !ok || boolean.Value != false {
+		t.Errorf("second statement not folded to Boolean false. got=%#v",
+			folded.Statements[1].(*ast.ExpressionStatement).Expression)
+	}
+}
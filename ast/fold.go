@@ -0,0 +1,150 @@
+package ast
+
+import (
+	"math"
+	"math/big"
+	"monkey/token"
+	"strconv"
+)
+
+// Fold rewrites the tree rooted at node, replacing subtrees that only
+// involve integer and boolean literals with the literal they evaluate to,
+// e.g. 2 + 3 becomes 5 and !true becomes false.
+// Anything depending on identifiers or calls is left untouched, and so are
+// operations that fail at runtime, such as division by zero or overflow.
+func Fold(node Node) Node {
+	return Transform(node, foldNode)
+}
+
+// foldNode folds a single node whose children have already been folded
+func foldNode(node Node) Node {
+	switch node := node.(type) {
+	case *PrefixExpression:
+		if folded := foldPrefix(node); folded != nil {
+			return folded
+		}
+	case *InfixExpression:
+		if folded := foldInfix(node); folded != nil {
+			return folded
+		}
+	}
+	return node
+}
+
+// foldPrefix returns the literal equivalent to pe, or nil if it can't be folded
+func foldPrefix(pe *PrefixExpression) Expression {
+	switch right := pe.Right.(type) {
+	case *IntegerLiteral:
+		switch pe.Operator {
+		case "-":
+			if right.Value == math.MinInt64 {
+				// overflows, leave it to the evaluator to report
+				return nil
+			}
+			return newIntegerLiteral(pe.Token.Pos, -right.Value)
+		case "~":
+			return newIntegerLiteral(pe.Token.Pos, ^right.Value)
+		case "!":
+			// integers are always truthy
+			return newBooleanLiteral(pe.Token.Pos, false)
+		}
+	case *Boolean:
+		if pe.Operator == "!" {
+			return newBooleanLiteral(pe.Token.Pos, !right.Value)
+		}
+	}
+	return nil
+}
+
+// foldInfix returns the literal equivalent to ie, or nil if it can't be folded
+func foldInfix(ie *InfixExpression) Expression {
+	pos := ie.Pos()
+
+	switch left := ie.Left.(type) {
+	case *IntegerLiteral:
+		right, ok := ie.Right.(*IntegerLiteral)
+		if !ok {
+			return nil
+		}
+		switch ie.Operator {
+		case "+", "-", "*", "/", "%":
+			value, ok := foldArithmetic(ie.Operator, left.Value, right.Value)
+			if !ok {
+				return nil
+			}
+			return newIntegerLiteral(pos, value)
+		case "<":
+			return newBooleanLiteral(pos, left.Value < right.Value)
+		case ">":
+			return newBooleanLiteral(pos, left.Value > right.Value)
+		case "==":
+			return newBooleanLiteral(pos, left.Value == right.Value)
+		case "!=":
+			return newBooleanLiteral(pos, left.Value != right.Value)
+		}
+	case *Boolean:
+		right, ok := ie.Right.(*Boolean)
+		if !ok {
+			return nil
+		}
+		switch ie.Operator {
+		case "==":
+			return newBooleanLiteral(pos, left.Value == right.Value)
+		case "!=":
+			return newBooleanLiteral(pos, left.Value != right.Value)
+		}
+	}
+	return nil
+}
+
+// foldArithmetic applies operator to a and b. It reports false when
+// the result is an error at runtime, i.e. division by zero or a result
+// that overflows an int64, so that the evaluator can report it
+func foldArithmetic(operator string, a, b int64) (int64, bool) {
+	x, y := big.NewInt(a), big.NewInt(b)
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(x, y)
+	case "-":
+		result.Sub(x, y)
+	case "*":
+		result.Mul(x, y)
+	case "/":
+		if b == 0 {
+			return 0, false
+		}
+		// Quo truncates towards zero like Go's integer division
+		result.Quo(x, y)
+	case "%":
+		if b == 0 {
+			return 0, false
+		}
+		// Rem has the sign of the dividend like Go's remainder
+		result.Rem(x, y)
+	}
+
+	if !result.IsInt64() {
+		return 0, false
+	}
+	return result.Int64(), true
+}
+
+// newIntegerLiteral returns an IntegerLiteral as if value had been parsed at pos
+func newIntegerLiteral(pos token.Position, value int64) *IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: literal, Pos: pos},
+		Value: value,
+	}
+}
+
+// newBooleanLiteral returns a Boolean as if value had been parsed at pos
+func newBooleanLiteral(pos token.Position, value bool) *Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+	if value {
+		tok = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+	}
+	return &Boolean{Token: tok, Value: value}
+}
@@ -0,0 +1,390 @@
+package ast
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// indentation used for each nested block by Format
+const formatIndent = "  "
+
+// binding strength of each kind of expression, from loosest to tightest.
+// They mirror the parser precedences and decide where Format needs
+// parentheses to keep the meaning of the tree
+const (
+	_ int = iota
+	formatLowest
+	formatAssign
+	formatTernary
+	formatOr
+	formatAnd
+	formatEquals
+	formatLessGreater
+	formatSum
+	formatProduct
+	formatPrefix
+	formatPostfix
+	formatCall
+	formatIndex
+	formatAtom
+)
+
+// binding strength of each infix operator
+var formatPrecedences = map[string]int{
+	"||": formatOr,
+	"&&": formatAnd,
+	"==": formatEquals,
+	"!=": formatEquals,
+	"<":  formatLessGreater,
+	">":  formatLessGreater,
+	"+":  formatSum,
+	"-":  formatSum,
+	"*":  formatProduct,
+	"/":  formatProduct,
+	"%":  formatProduct,
+}
+
+// Format renders the tree rooted at n as canonical monkey source: one
+// statement per line, two space indentation inside blocks, spaces around
+// infix operators and only the parentheses needed to preserve meaning.
+// Unlike String, its output parses back into an equivalent tree.
+func Format(n Node) string {
+	f := &formatter{}
+	f.node(n)
+	return f.out.String()
+}
+
+// formatter accumulates formatted source at the current block depth
+type formatter struct {
+	out   bytes.Buffer
+	depth int
+}
+
+func (f *formatter) write(s string) {
+	f.out.WriteString(s)
+}
+
+func (f *formatter) indent() {
+	f.write(strings.Repeat(formatIndent, f.depth))
+}
+
+// node writes a Program or a single statement or expression
+func (f *formatter) node(n Node) {
+	switch n := n.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			f.statement(s)
+		}
+	case *BlockStatement:
+		f.block(n)
+	case Statement:
+		f.statement(n)
+	case Expression:
+		f.expression(n, formatLowest)
+	}
+}
+
+// statement writes s on its own line
+func (f *formatter) statement(s Statement) {
+	f.indent()
+
+	switch s := s.(type) {
+	case *LetStatement:
+		f.write("let " + s.Target().String() + " = ")
+		f.expression(s.Value, formatLowest)
+		f.write(";")
+	case *ConstStatement:
+		f.write("const " + s.Name.String() + " = ")
+		f.expression(s.Value, formatLowest)
+		f.write(";")
+	case *ReturnStatement:
+		f.write("return")
+		if s.ReturnValue != nil {
+			f.write(" ")
+			f.expression(s.ReturnValue, formatLowest)
+		}
+		f.write(";")
+	case *BreakStatement:
+		f.write("break;")
+	case *ContinueStatement:
+		f.write("continue;")
+	case *ExpressionStatement:
+		f.expression(s.Expression, formatLowest)
+		switch s.Expression.(type) {
+		case *IfExpression, *WhileExpression, *ForExpression, *SwitchExpression:
+		default:
+			f.write(";")
+		}
+	case *BlockStatement:
+		f.block(s)
+	}
+
+	f.write("\n")
+}
+
+// block writes the braces of b and its statements one level deeper
+func (f *formatter) block(b *BlockStatement) {
+	if b == nil || len(b.Statements) == 0 {
+		f.write("{}")
+		return
+	}
+
+	f.write("{\n")
+	f.depth++
+	for _, s := range b.Statements {
+		f.statement(s)
+	}
+	f.depth--
+	f.indent()
+	f.write("}")
+}
+
+// expression writes e, wrapping it in parentheses when it binds looser
+// than min
+func (f *formatter) expression(e Expression, min int) {
+	if e == nil {
+		return
+	}
+
+	strength := bindingStrength(e)
+	if strength < min {
+		f.write("(")
+		defer f.write(")")
+	}
+
+	switch e := e.(type) {
+	case *Identifier:
+		f.write(e.Value)
+	case *Boolean, *IntegerLiteral:
+		f.write(e.String())
+	case *StringLiteral:
+		f.write(`"` + e.Value + `"`)
+	case *PrefixExpression:
+		f.write(e.Operator)
+		if e.Operator == "-" && startsWithMinus(e.Right) {
+			// keep "- -x" from being read back as a decrement
+			f.write(" ")
+		}
+		f.expression(e.Right, formatPrefix)
+	case *PostfixExpression:
+		f.expression(e.Left, formatPostfix)
+		f.write(e.Operator)
+	case *InfixExpression:
+		f.expression(e.Left, strength)
+		f.write(" " + e.Operator + " ")
+		f.expression(e.Right, strength+1)
+	case *AssignExpression:
+		f.write(e.Name.Value + " = ")
+		f.expression(e.Value, formatAssign)
+	case *TernaryExpression:
+		f.expression(e.Condition, formatTernary+1)
+		f.write(" ? ")
+		f.expression(e.Consequence, formatLowest)
+		f.write(" : ")
+		f.expression(e.Alternative, formatTernary)
+	case *IfExpression:
+		f.write("if (")
+		f.expression(e.Condition, formatLowest)
+		f.write(") ")
+		f.block(e.Consequence)
+		if e.Alternative != nil {
+			f.write(" else ")
+			if elseIf, ok := singleIf(e.Alternative); ok {
+				f.expression(elseIf, formatLowest)
+			} else {
+				f.block(e.Alternative)
+			}
+		}
+	case *SwitchExpression:
+		f.write("switch (")
+		f.expression(e.Subject, formatLowest)
+		f.write(") {\n")
+		f.depth++
+		for _, c := range e.Cases {
+			f.indent()
+			f.write("case ")
+			f.expression(c.Value, formatLowest)
+			f.write(" ")
+			f.block(c.Body)
+			f.write("\n")
+		}
+		if e.Default != nil {
+			f.indent()
+			f.write("default ")
+			f.block(e.Default)
+			f.write("\n")
+		}
+		f.depth--
+		f.indent()
+		f.write("}")
+	case *WhileExpression:
+		f.write("while (")
+		f.expression(e.Condition, formatLowest)
+		f.write(") ")
+		f.block(e.Body)
+	case *ForExpression:
+		f.write("for (")
+		switch init := e.Init.(type) {
+		case *LetStatement:
+			f.write("let " + init.Target().String() + " = ")
+			f.expression(init.Value, formatLowest)
+		case *ExpressionStatement:
+			f.expression(init.Expression, formatLowest)
+		}
+		f.write(";")
+		if e.Condition != nil {
+			f.write(" ")
+			f.expression(e.Condition, formatLowest)
+		}
+		f.write(";")
+		if e.Update != nil {
+			f.write(" ")
+			f.expression(e.Update, formatLowest)
+		}
+		f.write(") ")
+		f.block(e.Body)
+	case *DoWhileExpression:
+		f.write("do ")
+		f.block(e.Body)
+		f.write(" while (")
+		f.expression(e.Condition, formatLowest)
+		f.write(")")
+	case *FunctionLiteral:
+		f.write("fn(")
+		for i, p := range e.Parameters {
+			if i > 0 {
+				f.write(", ")
+			}
+			f.write(p.Value)
+			if def, ok := e.Defaults[p.Value]; ok {
+				f.write(" = ")
+				f.expression(def, formatAssign)
+			}
+		}
+		f.write(") ")
+		f.block(e.Body)
+	case *MacroLiteral:
+		f.write("macro")
+		f.parameters(e.Parameters)
+		f.block(e.Body)
+	case *CallExpression:
+		f.expression(e.Function, formatCall)
+		f.write("(")
+		f.list(e.Arguments)
+		f.write(")")
+	case *ArrayLiteral:
+		f.write("[")
+		f.list(e.Elements)
+		f.write("]")
+	case *IndexExpression:
+		f.expression(e.Left, formatCall)
+		f.write("[")
+		f.expression(e.Index, formatLowest)
+		f.write("]")
+	case *SliceExpression:
+		f.expression(e.Left, formatCall)
+		f.write("[")
+		f.expression(e.Low, formatLowest)
+		f.write(":")
+		f.expression(e.High, formatLowest)
+		f.write("]")
+	case *HashLiteral:
+		keys := make([]Expression, 0, len(e.Pairs))
+		for key := range e.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		f.write("{")
+		for i, key := range keys {
+			if i > 0 {
+				f.write(", ")
+			}
+			f.expression(key, formatLowest)
+			f.write(": ")
+			f.expression(e.Pairs[key], formatLowest)
+		}
+		f.write("}")
+	default:
+		f.write(e.String())
+	}
+}
+
+// parameters writes a parenthesised parameter list followed by a space
+func (f *formatter) parameters(params []*Identifier) {
+	names := make([]string, 0, len(params))
+	for _, p := range params {
+		names = append(names, p.Value)
+	}
+	f.write("(" + strings.Join(names, ", ") + ") ")
+}
+
+// list writes a comma separated list of expressions
+func (f *formatter) list(exps []Expression) {
+	for i, e := range exps {
+		if i > 0 {
+			f.write(", ")
+		}
+		f.expression(e, formatLowest)
+	}
+}
+
+// bindingStrength returns how tightly e holds together when it is the
+// operand of another expression
+func bindingStrength(e Expression) int {
+	switch e := e.(type) {
+	case *AssignExpression:
+		return formatAssign
+	case *TernaryExpression:
+		return formatTernary
+	case *InfixExpression:
+		if p, ok := formatPrecedences[e.Operator]; ok {
+			return p
+		}
+		return formatLowest
+	case *PrefixExpression:
+		return formatPrefix
+	case *IntegerLiteral:
+		if e.Value < 0 {
+			return formatPrefix
+		}
+		return formatAtom
+	case *PostfixExpression:
+		return formatPostfix
+	case *CallExpression:
+		return formatCall
+	case *IndexExpression, *SliceExpression:
+		return formatIndex
+	default:
+		return formatAtom
+	}
+}
+
+// startsWithMinus reports whether the formatted e begins with a minus sign
+func startsWithMinus(e Expression) bool {
+	switch e := e.(type) {
+	case *PrefixExpression:
+		return e.Operator == "-"
+	case *IntegerLiteral:
+		return e.Value < 0
+	default:
+		return false
+	}
+}
+
+// singleIf returns the if expression b consists of, if that is all it
+// holds, so that it can be written as an else if
+func singleIf(b *BlockStatement) (*IfExpression, bool) {
+	if len(b.Statements) != 1 {
+		return nil, false
+	}
+	stmt, ok := b.Statements[0].(*ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := stmt.Expression.(*IfExpression)
+	return ie, ok
+}
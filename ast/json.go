@@ -0,0 +1,222 @@
+package ast
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// jsonNode is the JSON representation of a node: its type name
+// alongside its fields. Child nodes are nested as they are
+type jsonNode map[string]interface{}
+
+// marshalNode encodes a node of the given type name with its fields
+func marshalNode(name string, fields jsonNode) ([]byte, error) {
+	fields["type"] = name
+	return json.Marshal(fields)
+}
+
+// MarshalJSON encodes the program and all of its statements as JSON
+func (p *Program) MarshalJSON() ([]byte, error) {
+	return marshalNode("Program", jsonNode{"statements": p.Statements})
+}
+
+// Statements
+func (ls *LetStatement) MarshalJSON() ([]byte, error) {
+	if ls.Pattern != nil {
+		return marshalNode("LetStatement", jsonNode{"pattern": ls.Pattern, "value": ls.Value})
+	}
+	return marshalNode("LetStatement", jsonNode{"name": ls.Name, "value": ls.Value})
+}
+
+func (cs *ConstStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ConstStatement", jsonNode{"name": cs.Name, "value": cs.Value})
+}
+
+func (ap *ArrayPattern) MarshalJSON() ([]byte, error) {
+	fields := jsonNode{"elements": ap.Elements}
+	if ap.Rest != nil {
+		fields["rest"] = ap.Rest
+	}
+	return marshalNode("ArrayPattern", fields)
+}
+
+func (hp *HashPattern) MarshalJSON() ([]byte, error) {
+	entries := make([]jsonNode, 0, len(hp.Entries))
+	for _, e := range hp.Entries {
+		entries = append(entries, jsonNode{"key": e.Key.Value, "name": e.Name})
+	}
+	return marshalNode("HashPattern", jsonNode{"entries": entries})
+}
+
+func (rs *ReturnStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ReturnStatement", jsonNode{"returnValue": rs.ReturnValue})
+}
+
+func (bs *BreakStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("BreakStatement", jsonNode{})
+}
+
+func (cs *ContinueStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ContinueStatement", jsonNode{})
+}
+
+func (es *ExpressionStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("ExpressionStatement", jsonNode{"expression": es.Expression})
+}
+
+func (bs *BlockStatement) MarshalJSON() ([]byte, error) {
+	return marshalNode("BlockStatement", jsonNode{"statements": bs.Statements})
+}
+
+// Expressions
+func (i *Identifier) MarshalJSON() ([]byte, error) {
+	return marshalNode("Identifier", jsonNode{"value": i.Value})
+}
+
+func (b *Boolean) MarshalJSON() ([]byte, error) {
+	return marshalNode("Boolean", jsonNode{"value": b.Value})
+}
+
+func (il *IntegerLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("IntegerLiteral", jsonNode{"value": il.Value})
+}
+
+func (cl *CharLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("CharLiteral", jsonNode{"value": string(cl.Value)})
+}
+
+func (sl *StringLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("StringLiteral", jsonNode{"value": sl.Value})
+}
+
+func (pe *PrefixExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("PrefixExpression", jsonNode{
+		"operator": pe.Operator,
+		"right":    pe.Right,
+	})
+}
+
+func (pe *PostfixExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("PostfixExpression", jsonNode{
+		"left":     pe.Left,
+		"operator": pe.Operator,
+	})
+}
+
+func (ie *InfixExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("InfixExpression", jsonNode{
+		"left":     ie.Left,
+		"operator": ie.Operator,
+		"right":    ie.Right,
+	})
+}
+
+func (ae *AssignExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("AssignExpression", jsonNode{"name": ae.Name, "value": ae.Value})
+}
+
+func (te *TernaryExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("TernaryExpression", jsonNode{
+		"condition":   te.Condition,
+		"consequence": te.Consequence,
+		"alternative": te.Alternative,
+	})
+}
+
+func (ie *IfExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("IfExpression", jsonNode{
+		"condition":   ie.Condition,
+		"consequence": ie.Consequence,
+		"alternative": ie.Alternative,
+	})
+}
+
+func (se *SwitchExpression) MarshalJSON() ([]byte, error) {
+	cases := make([]jsonNode, 0, len(se.Cases))
+	for _, c := range se.Cases {
+		cases = append(cases, jsonNode{"value": c.Value, "body": c.Body})
+	}
+	return marshalNode("SwitchExpression", jsonNode{
+		"subject": se.Subject,
+		"cases":   cases,
+		"default": se.Default,
+	})
+}
+
+func (we *WhileExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("WhileExpression", jsonNode{
+		"condition": we.Condition,
+		"body":      we.Body,
+	})
+}
+
+func (fe *ForExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("ForExpression", jsonNode{
+		"init":      fe.Init,
+		"condition": fe.Condition,
+		"update":    fe.Update,
+		"body":      fe.Body,
+	})
+}
+
+func (dw *DoWhileExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("DoWhileExpression", jsonNode{
+		"body":      dw.Body,
+		"condition": dw.Condition,
+	})
+}
+
+func (fl *FunctionLiteral) MarshalJSON() ([]byte, error) {
+	fields := jsonNode{
+		"parameters": fl.Parameters,
+		"body":       fl.Body,
+	}
+	if len(fl.Defaults) > 0 {
+		fields["defaults"] = fl.Defaults
+	}
+	return marshalNode("FunctionLiteral", fields)
+}
+
+func (ml *MacroLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("MacroLiteral", jsonNode{
+		"parameters": ml.Parameters,
+		"body":       ml.Body,
+	})
+}
+
+func (ce *CallExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("CallExpression", jsonNode{
+		"function":  ce.Function,
+		"arguments": ce.Arguments,
+	})
+}
+
+func (al *ArrayLiteral) MarshalJSON() ([]byte, error) {
+	return marshalNode("ArrayLiteral", jsonNode{"elements": al.Elements})
+}
+
+func (ie *IndexExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("IndexExpression", jsonNode{"left": ie.Left, "index": ie.Index})
+}
+
+func (se *SliceExpression) MarshalJSON() ([]byte, error) {
+	return marshalNode("SliceExpression", jsonNode{
+		"left": se.Left,
+		"low":  se.Low,
+		"high": se.High,
+	})
+}
+
+// MarshalJSON encodes the pairs as a list sorted by the keys' source,
+// so the output does not depend on map iteration order
+func (hl *HashLiteral) MarshalJSON() ([]byte, error) {
+	pairs := make([]jsonNode, 0, len(hl.Pairs))
+	for key, value := range hl.Pairs {
+		pairs = append(pairs, jsonNode{"key": key, "value": value})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i]["key"].(Expression).String() < pairs[j]["key"].(Expression).String()
+	})
+
+	return marshalNode("HashLiteral", jsonNode{"pairs": pairs})
+}
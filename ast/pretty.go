@@ -0,0 +1,192 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// indentation used for each level of the tree by PrettyPrint
+const prettyIndent = "  "
+
+// PrettyPrint renders the tree rooted at n with one node per line,
+// each child indented below its parent. Every line shows the node type
+// followed by its key fields, e.g. the operator of an InfixExpression.
+// It is meant for debugging, unlike String which renders source-like text.
+func PrettyPrint(n Node) string {
+	var out bytes.Buffer
+	prettyPrint(&out, n, 0)
+	return out.String()
+}
+
+// prettyPrint writes n and its children to out at the given depth
+func prettyPrint(out *bytes.Buffer, n Node, depth int) {
+	line := func(format string, a ...interface{}) {
+		out.WriteString(strings.Repeat(prettyIndent, depth))
+		fmt.Fprintf(out, format, a...)
+		out.WriteString("\n")
+	}
+	child := func(c Node) {
+		prettyPrint(out, c, depth+1)
+	}
+
+	if n == nil {
+		line("<nil>")
+		return
+	}
+
+	switch n := n.(type) {
+	// Statements
+	case *Program:
+		line("Program")
+		for _, s := range n.Statements {
+			child(s)
+		}
+	case *LetStatement:
+		line("LetStatement %s", n.Target().String())
+		child(n.Value)
+	case *ConstStatement:
+		line("ConstStatement %s", n.Name.String())
+		child(n.Value)
+	case *ReturnStatement:
+		line("ReturnStatement")
+		child(n.ReturnValue)
+	case *BreakStatement:
+		line("BreakStatement")
+	case *ContinueStatement:
+		line("ContinueStatement")
+	case *ExpressionStatement:
+		line("ExpressionStatement")
+		child(n.Expression)
+	case *BlockStatement:
+		line("BlockStatement")
+		for _, s := range n.Statements {
+			child(s)
+		}
+
+	// Expressions
+	case *Identifier:
+		line("Identifier %s", n.Value)
+	case *Boolean:
+		line("Boolean %t", n.Value)
+	case *IntegerLiteral:
+		line("IntegerLiteral %d", n.Value)
+	case *StringLiteral:
+		line("StringLiteral %q", n.Value)
+	case *CharLiteral:
+		line("CharLiteral %q", n.Value)
+	case *PrefixExpression:
+		line("PrefixExpression %s", n.Operator)
+		child(n.Right)
+	case *PostfixExpression:
+		line("PostfixExpression %s", n.Operator)
+		child(n.Left)
+	case *InfixExpression:
+		line("InfixExpression %s", n.Operator)
+		child(n.Left)
+		child(n.Right)
+	case *AssignExpression:
+		line("AssignExpression %s", n.Name.Value)
+		child(n.Value)
+	case *TernaryExpression:
+		line("TernaryExpression")
+		child(n.Condition)
+		child(n.Consequence)
+		child(n.Alternative)
+	case *IfExpression:
+		line("IfExpression")
+		child(n.Condition)
+		child(n.Consequence)
+		if n.Alternative != nil {
+			child(n.Alternative)
+		}
+	case *SwitchExpression:
+		line("SwitchExpression")
+		child(n.Subject)
+		for _, c := range n.Cases {
+			out.WriteString(strings.Repeat(prettyIndent, depth+1) + "SwitchCase\n")
+			prettyPrint(out, c.Value, depth+2)
+			prettyPrint(out, c.Body, depth+2)
+		}
+		if n.Default != nil {
+			out.WriteString(strings.Repeat(prettyIndent, depth+1) + "SwitchDefault\n")
+			prettyPrint(out, n.Default, depth+2)
+		}
+	case *WhileExpression:
+		line("WhileExpression")
+		child(n.Condition)
+		child(n.Body)
+	case *ForExpression:
+		line("ForExpression")
+		if n.Init != nil {
+			child(n.Init)
+		}
+		if n.Condition != nil {
+			child(n.Condition)
+		}
+		if n.Update != nil {
+			child(n.Update)
+		}
+		child(n.Body)
+	case *DoWhileExpression:
+		line("DoWhileExpression")
+		child(n.Body)
+		child(n.Condition)
+	case *FunctionLiteral:
+		params := []string{}
+		for _, p := range n.Parameters {
+			params = append(params, p.Value)
+		}
+		line("FunctionLiteral (%s)", strings.Join(params, ", "))
+		for _, p := range n.Parameters {
+			if def, ok := n.Defaults[p.Value]; ok {
+				out.WriteString(strings.Repeat(prettyIndent, depth+1) + "Default " + p.Value + "\n")
+				prettyPrint(out, def, depth+2)
+			}
+		}
+		child(n.Body)
+	case *MacroLiteral:
+		params := []string{}
+		for _, p := range n.Parameters {
+			params = append(params, p.Value)
+		}
+		line("MacroLiteral (%s)", strings.Join(params, ", "))
+		child(n.Body)
+	case *CallExpression:
+		line("CallExpression")
+		child(n.Function)
+		for _, a := range n.Arguments {
+			child(a)
+		}
+	case *ArrayLiteral:
+		line("ArrayLiteral")
+		for _, el := range n.Elements {
+			child(el)
+		}
+	case *IndexExpression:
+		line("IndexExpression")
+		child(n.Left)
+		child(n.Index)
+	case *SliceExpression:
+		line("SliceExpression")
+		child(n.Left)
+		child(n.Low)
+		child(n.High)
+	case *HashLiteral:
+		line("HashLiteral")
+		keys := make([]Expression, 0, len(n.Pairs))
+		for key := range n.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, key := range keys {
+			child(key)
+			child(n.Pairs[key])
+		}
+	default:
+		line("%T", n)
+	}
+}
@@ -0,0 +1,147 @@
+package ast
+
+// TransformFunc receives a node whose children have already been transformed
+// and returns the node that should take its place in the tree
+type TransformFunc func(Node) Node
+
+// Transform walks the tree rooted at node bottom-up, replacing each node with
+// the result of calling fn on it. Children are transformed first and
+// reattached to their parent before fn is called on the parent itself.
+// Nodes are rewritten in place, so callers that need the original tree
+// intact should parse it again.
+func Transform(node Node, fn TransformFunc) Node {
+	if node == nil {
+		return nil
+	}
+
+	switch node := node.(type) {
+	// Statements
+	case *Program:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Transform(statement, fn).(Statement)
+		}
+	case *LetStatement:
+		if node.Pattern != nil {
+			node.Pattern, _ = Transform(node.Pattern, fn).(Pattern)
+		} else {
+			node.Name, _ = Transform(node.Name, fn).(*Identifier)
+		}
+		node.Value, _ = Transform(node.Value, fn).(Expression)
+	case *ConstStatement:
+		node.Name, _ = Transform(node.Name, fn).(*Identifier)
+		node.Value, _ = Transform(node.Value, fn).(Expression)
+	case *ArrayPattern:
+		for i, el := range node.Elements {
+			node.Elements[i], _ = Transform(el, fn).(*Identifier)
+		}
+		if node.Rest != nil {
+			node.Rest, _ = Transform(node.Rest, fn).(*Identifier)
+		}
+	case *HashPattern:
+		for _, e := range node.Entries {
+			e.Name, _ = Transform(e.Name, fn).(*Identifier)
+		}
+	case *ReturnStatement:
+		node.ReturnValue, _ = Transform(node.ReturnValue, fn).(Expression)
+	case *ExpressionStatement:
+		node.Expression, _ = Transform(node.Expression, fn).(Expression)
+	case *BlockStatement:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Transform(statement, fn).(Statement)
+		}
+
+	// Expressions
+	case *PrefixExpression:
+		node.Right, _ = Transform(node.Right, fn).(Expression)
+	case *PostfixExpression:
+		node.Left, _ = Transform(node.Left, fn).(Expression)
+	case *InfixExpression:
+		node.Left, _ = Transform(node.Left, fn).(Expression)
+		node.Right, _ = Transform(node.Right, fn).(Expression)
+	case *AssignExpression:
+		node.Name, _ = Transform(node.Name, fn).(*Identifier)
+		node.Value, _ = Transform(node.Value, fn).(Expression)
+	case *TernaryExpression:
+		node.Condition, _ = Transform(node.Condition, fn).(Expression)
+		node.Consequence, _ = Transform(node.Consequence, fn).(Expression)
+		node.Alternative, _ = Transform(node.Alternative, fn).(Expression)
+	case *IfExpression:
+		node.Condition, _ = Transform(node.Condition, fn).(Expression)
+		node.Consequence, _ = Transform(node.Consequence, fn).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative, _ = Transform(node.Alternative, fn).(*BlockStatement)
+		}
+	case *SwitchExpression:
+		node.Subject, _ = Transform(node.Subject, fn).(Expression)
+		for _, c := range node.Cases {
+			c.Value, _ = Transform(c.Value, fn).(Expression)
+			c.Body, _ = Transform(c.Body, fn).(*BlockStatement)
+		}
+		if node.Default != nil {
+			node.Default, _ = Transform(node.Default, fn).(*BlockStatement)
+		}
+	case *WhileExpression:
+		node.Condition, _ = Transform(node.Condition, fn).(Expression)
+		node.Body, _ = Transform(node.Body, fn).(*BlockStatement)
+	case *ForExpression:
+		if node.Init != nil {
+			node.Init, _ = Transform(node.Init, fn).(Statement)
+		}
+		if node.Condition != nil {
+			node.Condition, _ = Transform(node.Condition, fn).(Expression)
+		}
+		if node.Update != nil {
+			node.Update, _ = Transform(node.Update, fn).(Expression)
+		}
+		node.Body, _ = Transform(node.Body, fn).(*BlockStatement)
+	case *DoWhileExpression:
+		node.Body, _ = Transform(node.Body, fn).(*BlockStatement)
+		node.Condition, _ = Transform(node.Condition, fn).(Expression)
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i], _ = Transform(param, fn).(*Identifier)
+		}
+		for name, def := range node.Defaults {
+			node.Defaults[name], _ = Transform(def, fn).(Expression)
+		}
+		node.Body, _ = Transform(node.Body, fn).(*BlockStatement)
+	case *MacroLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i], _ = Transform(param, fn).(*Identifier)
+		}
+		node.Body, _ = Transform(node.Body, fn).(*BlockStatement)
+	case *CallExpression:
+		node.Function, _ = Transform(node.Function, fn).(Expression)
+		for i, arg := range node.Arguments {
+			node.Arguments[i], _ = Transform(arg, fn).(Expression)
+		}
+	case *ArrayLiteral:
+		for i, el := range node.Elements {
+			node.Elements[i], _ = Transform(el, fn).(Expression)
+		}
+	case *IndexExpression:
+		node.Left, _ = Transform(node.Left, fn).(Expression)
+		node.Index, _ = Transform(node.Index, fn).(Expression)
+	case *SliceExpression:
+		node.Left, _ = Transform(node.Left, fn).(Expression)
+		node.Low, _ = Transform(node.Low, fn).(Expression)
+		node.High, _ = Transform(node.High, fn).(Expression)
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for key, value := range node.Pairs {
+			newKey, _ := Transform(key, fn).(Expression)
+			newValue, _ := Transform(value, fn).(Expression)
+			pairs[newKey] = newValue
+		}
+		node.Pairs = pairs
+	}
+
+	return fn(node)
+}
+
+// Modify rewrites the tree rooted at node bottom-up using modifier. It is the
+// entry point used for macro-like rewriting and shares its traversal with
+// Transform.
+func Modify(node Node, modifier func(Node) Node) Node {
+	return Transform(node, modifier)
+}
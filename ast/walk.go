@@ -0,0 +1,133 @@
+package ast
+
+// Walk traverses the tree rooted at n depth-first, calling fn on each node
+// before visiting its children. If fn returns false, the children of that
+// node are skipped.
+func Walk(n Node, fn func(Node) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+
+	switch n := n.(type) {
+	// Statements
+	case *Program:
+		for _, statement := range n.Statements {
+			Walk(statement, fn)
+		}
+	case *LetStatement:
+		if n.Pattern != nil {
+			Walk(n.Pattern, fn)
+		} else {
+			Walk(n.Name, fn)
+		}
+		walkExpression(n.Value, fn)
+	case *ConstStatement:
+		Walk(n.Name, fn)
+		walkExpression(n.Value, fn)
+	case *ArrayPattern:
+		for _, el := range n.Elements {
+			Walk(el, fn)
+		}
+		if n.Rest != nil {
+			Walk(n.Rest, fn)
+		}
+	case *HashPattern:
+		// keys are not references to names, so only the bound names are visited
+		for _, e := range n.Entries {
+			Walk(e.Name, fn)
+		}
+	case *ReturnStatement:
+		walkExpression(n.ReturnValue, fn)
+	case *ExpressionStatement:
+		walkExpression(n.Expression, fn)
+	case *BlockStatement:
+		for _, statement := range n.Statements {
+			Walk(statement, fn)
+		}
+
+	// Expressions
+	case *PrefixExpression:
+		walkExpression(n.Right, fn)
+	case *PostfixExpression:
+		walkExpression(n.Left, fn)
+	case *InfixExpression:
+		walkExpression(n.Left, fn)
+		walkExpression(n.Right, fn)
+	case *AssignExpression:
+		Walk(n.Name, fn)
+		walkExpression(n.Value, fn)
+	case *TernaryExpression:
+		walkExpression(n.Condition, fn)
+		walkExpression(n.Consequence, fn)
+		walkExpression(n.Alternative, fn)
+	case *IfExpression:
+		walkExpression(n.Condition, fn)
+		Walk(n.Consequence, fn)
+		if n.Alternative != nil {
+			Walk(n.Alternative, fn)
+		}
+	case *SwitchExpression:
+		walkExpression(n.Subject, fn)
+		for _, c := range n.Cases {
+			walkExpression(c.Value, fn)
+			Walk(c.Body, fn)
+		}
+		if n.Default != nil {
+			Walk(n.Default, fn)
+		}
+	case *WhileExpression:
+		walkExpression(n.Condition, fn)
+		Walk(n.Body, fn)
+	case *ForExpression:
+		if n.Init != nil {
+			Walk(n.Init, fn)
+		}
+		walkExpression(n.Condition, fn)
+		walkExpression(n.Update, fn)
+		Walk(n.Body, fn)
+	case *DoWhileExpression:
+		Walk(n.Body, fn)
+		walkExpression(n.Condition, fn)
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			Walk(param, fn)
+			if def, ok := n.Defaults[param.Value]; ok {
+				walkExpression(def, fn)
+			}
+		}
+		Walk(n.Body, fn)
+	case *MacroLiteral:
+		for _, param := range n.Parameters {
+			Walk(param, fn)
+		}
+		Walk(n.Body, fn)
+	case *CallExpression:
+		walkExpression(n.Function, fn)
+		for _, arg := range n.Arguments {
+			walkExpression(arg, fn)
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			walkExpression(el, fn)
+		}
+	case *IndexExpression:
+		walkExpression(n.Left, fn)
+		walkExpression(n.Index, fn)
+	case *SliceExpression:
+		walkExpression(n.Left, fn)
+		walkExpression(n.Low, fn)
+		walkExpression(n.High, fn)
+	case *HashLiteral:
+		for key, value := range n.Pairs {
+			walkExpression(key, fn)
+			walkExpression(value, fn)
+		}
+	}
+}
+
+// walkExpression walks e unless it is a missing (nil) expression
+func walkExpression(e Expression, fn func(Node) bool) {
+	if e != nil {
+		Walk(e, fn)
+	}
+}
@@ -0,0 +1,178 @@
+// Package code defines the bytecode instructions run by the monkey virtual
+// machine, along with helpers to encode and disassemble them.
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is a sequence of encoded instructions, each an opcode
+// followed by its operands
+type Instructions []byte
+
+// String disassembles the instructions, one per line, each prefixed with
+// its offset
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			return out.String()
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d", len(operands), len(def.OperandWidths))
+	}
+
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	}
+	return fmt.Sprintf("ERROR: unhandled operand count for %s", def.Name)
+}
+
+// Opcode identifies an instruction
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant at the index given by its operand
+	OpConstant Opcode = iota
+	// OpAdd pops two values and pushes their sum
+	OpAdd
+	// OpSub pops two values and pushes their difference
+	OpSub
+	// OpMul pops two values and pushes their product
+	OpMul
+	// OpDiv pops two values and pushes their quotient
+	OpDiv
+	// OpPop discards the value on top of the stack
+	OpPop
+	// OpTrue pushes true
+	OpTrue
+	// OpFalse pushes false
+	OpFalse
+	// OpEqual pops two values and pushes whether they are equal
+	OpEqual
+	// OpNotEqual pops two values and pushes whether they differ
+	OpNotEqual
+	// OpGreaterThan pops two values and pushes whether the first is
+	// greater. Less than comparisons swap their operands to use it
+	OpGreaterThan
+	// OpJumpNotTruthy pops a value and, unless it is truthy, jumps to the
+	// instruction at the offset given by its operand
+	OpJumpNotTruthy
+	// OpJump jumps to the instruction at the offset given by its operand
+	OpJump
+	// OpNull pushes null
+	OpNull
+	// OpGetGlobal pushes the global at the index given by its operand
+	OpGetGlobal
+	// OpSetGlobal pops a value and stores it in the global at the index
+	// given by its operand
+	OpSetGlobal
+)
+
+// Definition describes an opcode: its readable name and the number of
+// bytes taken by each of its operands
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpAdd:      {"OpAdd", []int{}},
+	OpSub:      {"OpSub", []int{}},
+	OpMul:      {"OpMul", []int{}},
+	OpDiv:      {"OpDiv", []int{}},
+	OpPop:      {"OpPop", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpEqual:    {"OpEqual", []int{}},
+	OpNotEqual: {"OpNotEqual", []int{}},
+
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+	OpNull:          {"OpNull", []int{}},
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+}
+
+// Lookup returns the definition of op
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes the instruction op with its operands, big endian. It
+// returns an empty instruction for undefined opcodes
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction defined by def from
+// ins, which starts right after the opcode. It returns them along with the
+// number of bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint16 decodes a two byte operand
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
@@ -0,0 +1,243 @@
+// Package compiler turns monkey ASTs into bytecode for the virtual machine.
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+)
+
+// Compiler accumulates the instructions and constants of the nodes it
+// compiles
+type Compiler struct {
+	instructions code.Instructions
+	constants    []object.Object
+	symbolTable  *SymbolTable
+
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction // the one before lastInstruction
+}
+
+// EmittedInstruction records an instruction appended by the Compiler
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// Bytecode is the output of a Compiler, ready to be run by the VM
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+}
+
+// New returns an empty Compiler
+func New() *Compiler {
+	return &Compiler{
+		instructions: code.Instructions{},
+		constants:    []object.Object{},
+		symbolTable:  NewSymbolTable(),
+	}
+}
+
+// NewWithState returns a Compiler that carries on from an earlier one,
+// resolving the names in symbolTable and adding to constants, so that a
+// REPL can compile each line against the bindings of the previous ones
+func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler {
+	c := New()
+	c.symbolTable = symbolTable
+	c.constants = constants
+	return c
+}
+
+// Compile appends the bytecode of node. Nodes the virtual machine cannot
+// run yet are reported as errors
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			return fmt.Errorf("%s: cannot compile %T", node.Pattern.Pos(), node.Pattern)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol := c.symbolTable.Define(node.Name.Value)
+		c.emit(code.OpSetGlobal, symbol.Index)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("%s: undefined variable %s", node.Pos(), node.Value)
+		}
+		c.emit(code.OpGetGlobal, symbol.Index)
+
+	case *ast.InfixExpression:
+		return c.compileInfix(node)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileInfix(node *ast.InfixExpression) error {
+	// there is no less than instruction, the operands are swapped to
+	// compare with greater than instead
+	if node.Operator == "<" {
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpGreaterThan)
+		return nil
+	}
+
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	switch node.Operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+	}
+	return nil
+}
+
+// compileIf compiles an if expression into a conditional jump over the
+// consequence and an unconditional one over the alternative, whose targets
+// are patched in once known. The branch not taken by a missing else
+// produces null
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// the offset is unknown until the consequence is compiled
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBranch(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.instructions))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBranch(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.instructions))
+	return nil
+}
+
+// compileBranch compiles a branch of an if so that it leaves its value on
+// the stack: the last expression is kept rather than popped, and branches
+// without one produce null
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// Bytecode returns what has been compiled so far
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.instructions,
+		Constants:    c.constants,
+	}
+}
+
+// addConstant stores obj in the constant pool and returns its index
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit appends an instruction and returns its position
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := len(c.instructions)
+	c.instructions = append(c.instructions, ins...)
+
+	c.previousInstruction = c.lastInstruction
+	c.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+	return pos
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	return len(c.instructions) != 0 && c.lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	c.instructions = c.instructions[:c.lastInstruction.Position]
+	c.lastInstruction = c.previousInstruction
+}
+
+// changeOperand rewrites the operand of the instruction at pos, which
+// must take a single one
+func (c *Compiler) changeOperand(pos int, operand int) {
+	op := code.Opcode(c.instructions[pos])
+	copy(c.instructions[pos:], code.Make(op, operand))
+}
@@ -0,0 +1,64 @@
+package compiler
+
+// SymbolScope tells where the value of a symbol is stored
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+)
+
+// Symbol is a name bound by the program being compiled, along with the
+// slot holding its value within its scope
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable keeps the symbols of a scope, falling back to the enclosing
+// scope for names it does not define
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+}
+
+// NewSymbolTable returns an empty table for the global scope
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: map[string]Symbol{}}
+}
+
+// NewEnclosedSymbolTable returns an empty table for a local scope, such
+// as a function body, nested in outer
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds name in this scope to the next free slot. Defining a name
+// again gives it a new slot, shadowing the previous one
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// Resolve returns the symbol bound to name in this scope or the closest
+// enclosing one defining it
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		return s.Outer.Resolve(name)
+	}
+	return obj, ok
+}
@@ -2,42 +2,45 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
 	"monkey/object"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-// map of builtin functions
+// map of builtin functions, see Builtins for the complete list
 var builtins = map[string]*object.Builtin{
 	"len": {
+		Name:    "len",
+		MinArgs: 1,
+		MaxArgs: 1,
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			switch arg := args[0].(type) {
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return newInteger(int64(len(arg.Elements)))
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return newInteger(int64(len(arg.Value)))
 			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
+				return newError(object.ArgumentError, "argument to `len` not supported, got %s", args[0].Type())
 			}
 		},
 	},
 	"type": {
+		Name:    "type",
+		MinArgs: 1,
+		MaxArgs: 1,
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			return &object.String{Value: string(args[0].Type())}
 		},
 	},
 	"first": {
+		Name:    "first",
+		MinArgs: 1,
+		MaxArgs: 1,
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+				return newError(object.ArgumentError, "argument to `first` must be ARRAY, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
 			if len(arr.Elements) > 0 {
@@ -47,13 +50,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": {
+		Name:    "last",
+		MinArgs: 1,
+		MaxArgs: 1,
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+				return newError(object.ArgumentError, "argument to `first` must be ARRAY, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
@@ -64,12 +66,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": {
+		Name:    "rest",
+		MinArgs: 1,
+		MaxArgs: 1,
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+				return newError(object.ArgumentError, "argument to `rest` must be ARRAY, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
@@ -82,12 +84,12 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": {
+		Name:    "push",
+		MinArgs: 2,
+		MaxArgs: 2,
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
-			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+				return newError(object.ArgumentError, "argument to `push` must be ARRAY, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
@@ -97,7 +99,269 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
+	"split": {
+		Name:    "split",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(args ...object.Object) object.Object {
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError(object.ArgumentError, "argument to `split` must be STRING, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError(object.ArgumentError, "separator for `split` must be STRING, got %s", args[1].Type())
+			}
+			// an empty separator splits after each character
+			parts := strings.Split(str.Value, sep.Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"join": {
+		Name:    "join",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(args ...object.Object) object.Object {
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError(object.ArgumentError, "argument to `join` must be ARRAY, got %s", args[0].Type())
+			}
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError(object.ArgumentError, "separator for `join` must be STRING, got %s", args[1].Type())
+			}
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return newError(object.ArgumentError, "elements joined by `join` must be STRING, got %s", el.Type())
+				}
+				parts[i] = str.Value
+			}
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+	},
+	"int": {
+		Name:    "int",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return newError(object.ArgumentError, "could not parse %q as integer", arg.Value)
+				}
+				return newInteger(value)
+			default:
+				return newError(object.ArgumentError, "argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"str": {
+		Name:    "str",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args ...object.Object) object.Object {
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+	"contains": {
+		Name:    "contains",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(args ...object.Object) object.Object {
+			index, err := indexOf("contains", args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(index >= 0)
+		},
+	},
+	"indexOf": {
+		Name:    "indexOf",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(args ...object.Object) object.Object {
+			index, err := indexOf("indexOf", args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return newInteger(index)
+		},
+	},
+	"reverse": {
+		Name:    "reverse",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args ...object.Object) object.Object {
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError(object.ArgumentError, "argument to `reverse` must be ARRAY, got %s", args[0].Type())
+			}
+			elements := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				elements[len(elements)-1-i] = el
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"keys": {
+		Name:    "keys",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("keys", args)
+			if err != nil {
+				return err
+			}
+			pairs := hash.SortedPairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"values": {
+		Name:    "values",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("values", args)
+			if err != nil {
+				return err
+			}
+			pairs := hash.SortedPairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"delete": {
+		Name:    "delete",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("delete", args)
+			if err != nil {
+				return err
+			}
+			if !object.IsHashable(args[1]) {
+				return newError(object.TypeMismatch, "unusable as hash key: %s", args[1].Type())
+			}
+			pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs))
+			for hashed, pair := range hash.Pairs {
+				pairs[hashed] = pair
+			}
+			delete(pairs, args[1].(object.Hashable).HashKey())
+			return &object.Hash{Pairs: pairs}
+		},
+	},
+	"set": {
+		Name:    "set",
+		MinArgs: 3,
+		MaxArgs: 3,
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("set", args)
+			if err != nil {
+				return err
+			}
+			if !object.IsHashable(args[1]) {
+				return newError(object.TypeMismatch, "unusable as hash key: %s", args[1].Type())
+			}
+			pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs)+1)
+			for hashed, pair := range hash.Pairs {
+				pairs[hashed] = pair
+			}
+			pairs[args[1].(object.Hashable).HashKey()] = object.HashPair{Key: args[1], Value: args[2]}
+			return &object.Hash{Pairs: pairs}
+		},
+	},
+	"abs": {
+		Name:    "abs",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args ...object.Object) object.Object {
+			integer, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError(object.ArgumentError, "argument to `abs` must be INTEGER, got %s", args[0].Type())
+			}
+			if integer.Value == math.MinInt64 {
+				return newError(object.ArithmeticError, "integer overflow: abs(%d)", integer.Value)
+			}
+			if integer.Value < 0 {
+				return newInteger(-integer.Value)
+			}
+			return integer
+		},
+	},
+	"min": {
+		Name:    "min",
+		MinArgs: 1,
+		MaxArgs: object.VARIADIC,
+		Fn: func(args ...object.Object) object.Object {
+			return extremum("min", args, func(a, b int64) bool { return a < b })
+		},
+	},
+	"max": {
+		Name:    "max",
+		MinArgs: 1,
+		MaxArgs: object.VARIADIC,
+		Fn: func(args ...object.Object) object.Object {
+			return extremum("max", args, func(a, b int64) bool { return a > b })
+		},
+	},
+	"range": {
+		Name:    "range",
+		MinArgs: 1,
+		MaxArgs: 3,
+		Fn: func(args ...object.Object) object.Object {
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError(object.ArgumentError, "arguments to `range` must be INTEGER, got %s", arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			// range(end), range(start, end) or range(start, end, step)
+			var start, end, step int64 = 0, bounds[0], 1
+			if len(bounds) > 1 {
+				start, end = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				step = bounds[2]
+			}
+			if step == 0 {
+				return newError(object.ArgumentError, "step of `range` must not be 0")
+			}
+			if step < 0 && start < end {
+				return newError(object.ArgumentError, "step of `range` must be positive from %d to %d, got %d", start, end, step)
+			}
+			count := rangeLength(start, end, step)
+			if count > maxRangeLength {
+				return newError(object.ArgumentError, "`range` of %d elements exceeds the limit of %d", count, maxRangeLength)
+			}
+			return &object.Array{Elements: integerRange(start, step, count)}
+		},
+	},
 	"print": {
+		Name:    "print",
+		MinArgs: 0,
+		MaxArgs: object.VARIADIC,
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
 				fmt.Println(arg.Inspect())
@@ -106,4 +370,148 @@ var builtins = map[string]*object.Builtin{
 			return NULL
 		},
 	},
+	"is_int":    typePredicate("is_int", object.INTEGER_OBJ),
+	"is_string": typePredicate("is_string", object.STRING_OBJ),
+	"is_array":  typePredicate("is_array", object.ARRAY_OBJ),
+	"is_hash":   typePredicate("is_hash", object.HASH_OBJ),
+	"is_fn":     typePredicate("is_fn", object.FUNCTION_OBJ, object.BUILTIN_OBJ),
+	"is_null":   typePredicate("is_null", object.NULL_OBJ),
+	"is_bool":   typePredicate("is_bool", object.BOOLEAN_OBJ),
+}
+
+// typePredicate returns a builtin called name that reports whether its
+// argument is of one of the given types
+func typePredicate(name string, types ...object.ObjectType) *object.Builtin {
+	return &object.Builtin{
+		Name:    name,
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(args ...object.Object) object.Object {
+			for _, t := range types {
+				if args[0].Type() == t {
+					return TRUE
+				}
+			}
+			return FALSE
+		},
+	}
+}
+
+// indexOf returns the position of the first element of an array equal to
+// item, or the byte offset of the first occurrence of item in a string,
+// and -1 when there is none. name is the builtin reporting errors
+func indexOf(name string, collection, item object.Object) (int64, *object.Error) {
+	switch collection := collection.(type) {
+	case *object.Array:
+		for i, el := range collection.Elements {
+			if object.Equals(el, item) {
+				return int64(i), nil
+			}
+		}
+		return -1, nil
+	case *object.String:
+		substr, ok := item.(*object.String)
+		if !ok {
+			return 0, newError(object.ArgumentError, "item searched by `%s` in a STRING must be STRING, got %s", name, item.Type())
+		}
+		return int64(strings.Index(collection.Value, substr.Value)), nil
+	default:
+		return 0, newError(object.ArgumentError, "first argument to `%s` must be ARRAY or STRING, got %s", name, collection.Type())
+	}
+}
+
+// hashArg checks that the first argument of the builtin name is a hash,
+// and returns that hash
+func hashArg(name string, args []object.Object) (*object.Hash, *object.Error) {
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return nil, newError(object.ArgumentError, "argument to `%s` must be HASH, got %s", name, args[0].Type())
+	}
+	return hash, nil
+}
+
+// extremum returns the first of the integer arguments of the builtin name
+// that no other argument beats
+func extremum(name string, args []object.Object, beats func(a, b int64) bool) object.Object {
+	var best *object.Integer
+	for _, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(object.ArgumentError, "arguments to `%s` must be INTEGER, got %s", name, arg.Type())
+		}
+		if best == nil || beats(integer.Value, best.Value) {
+			best = integer
+		}
+	}
+	return best
+}
+
+// maxRangeLength is the most elements `range` builds, so that a large
+// bound is reported rather than exhausting memory
+const maxRangeLength = 10000000
+
+// rangeLength returns how many integers there are from start up to, but
+// not including, end, counting by step. It works in unsigned arithmetic,
+// so that ranges ending near the integer limits stop rather than overflow
+func rangeLength(start, end, step int64) uint64 {
+	var span, stride uint64
+	switch {
+	case step > 0 && start < end:
+		span, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		span, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0
+	}
+	return (span-1)/stride + 1
+}
+
+// integerRange returns count integers from start, counting by step
+func integerRange(start, step int64, count uint64) []object.Object {
+	elements := make([]object.Object, count)
+	value := start
+	for i := range elements {
+		elements[i] = newInteger(value)
+		value += step
+	}
+	return elements
+}
+
+// checkArgs returns an error if the number of arguments given to the
+// builtin fn is not between its MinArgs and MaxArgs
+func checkArgs(fn *object.Builtin, args []object.Object) *object.Error {
+	name, min, max := fn.Name, fn.MinArgs, fn.MaxArgs
+	got := len(args)
+	if got >= min && (max == object.VARIADIC || got <= max) {
+		return nil
+	}
+
+	var want string
+	switch {
+	case min == max:
+		want = strconv.Itoa(min)
+	case max == object.VARIADIC:
+		want = "at least " + strconv.Itoa(min)
+	default:
+		want = strconv.Itoa(min) + " to " + strconv.Itoa(max)
+	}
+	return newError(object.ArgumentError, "wrong number of arguments to `%s`: got=%d, want=%s", name, got, want)
+}
+
+// Builtins returns every builtin function, sorted by name
+func Builtins() []*object.Builtin {
+	list := make([]*object.Builtin, 0, len(builtins)+len(higherOrderBuiltins))
+	for _, builtin := range builtins {
+		list = append(list, builtin)
+	}
+	e := New()
+	for _, name := range higherOrderBuiltins {
+		builtin, _ := e.higherOrderBuiltin(name)
+		list = append(list, builtin)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
 }
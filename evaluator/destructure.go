@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// binding is a value destructured out of another, with the name it is
+// bound to
+type binding struct {
+	name  string
+	value object.Object
+}
+
+// evalDestructuringLet evaluates a let statement whose target is a
+// pattern, binding each of its names to the matching part of the value
+func (e *Evaluator) evalDestructuringLet(ls *ast.LetStatement, env *object.Environment) object.Object {
+	seen := map[string]bool{}
+	for _, name := range patternNames(ls.Pattern) {
+		// shadowing is only allowed in nested scopes
+		if _, ok := env.GetLocal(name); ok || seen[name] {
+			return newError(object.Redefinition, "identifier already defined: %s", name)
+		}
+		seen[name] = true
+	}
+
+	val := e.Eval(ls.Value, env)
+	if isError(val) || isLoopControl(val) {
+		return val
+	}
+
+	bindings, err := e.destructure(ls.Pattern, val)
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings {
+		env.Set(b.name, b.value)
+	}
+	return nil
+}
+
+// patternNames returns the names bound by pattern
+func patternNames(pattern ast.Pattern) []string {
+	var names []string
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		for _, el := range pattern.Elements {
+			names = append(names, el.Value)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest.Value)
+		}
+	case *ast.HashPattern:
+		for _, entry := range pattern.Entries {
+			names = append(names, entry.Name.Value)
+		}
+	}
+	return names
+}
+
+// destructure matches val against pattern and returns the bindings it makes
+func (e *Evaluator) destructure(pattern ast.Pattern, val object.Object) ([]binding, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		return destructureArray(pattern, val)
+	case *ast.HashPattern:
+		return e.destructureHash(pattern, val)
+	default:
+		return nil, newError(object.GenericError, "unknown pattern: %s", pattern.String())
+	}
+}
+
+// destructureArray binds the names of pattern to the elements of val in
+// order, and the rest name to an array of the remaining elements
+func destructureArray(pattern *ast.ArrayPattern, val object.Object) ([]binding, *object.Error) {
+	arr, ok := val.(*object.Array)
+	if !ok {
+		return nil, newError(object.TypeMismatch, "cannot destructure %s as an array", val.Type())
+	}
+
+	want := len(pattern.Elements)
+	switch {
+	case pattern.Rest == nil && len(arr.Elements) != want:
+		return nil, newError(object.IndexError, "wrong number of values to destructure: expected %d, got %d",
+			want, len(arr.Elements))
+	case pattern.Rest != nil && len(arr.Elements) < want:
+		return nil, newError(object.IndexError, "wrong number of values to destructure: expected at least %d, got %d",
+			want, len(arr.Elements))
+	}
+
+	bindings := make([]binding, 0, want+1)
+	for i, el := range pattern.Elements {
+		bindings = append(bindings, binding{el.Value, arr.Elements[i]})
+	}
+	if pattern.Rest != nil {
+		rest := make([]object.Object, len(arr.Elements)-want)
+		copy(rest, arr.Elements[want:])
+		bindings = append(bindings, binding{pattern.Rest.Value, &object.Array{Elements: rest}})
+	}
+	return bindings, nil
+}
+
+// destructureHash binds the names of pattern to the values of val under
+// their string keys. Missing keys are bound to NULL, or are an error when
+// destructuring is strict
+func (e *Evaluator) destructureHash(pattern *ast.HashPattern, val object.Object) ([]binding, *object.Error) {
+	hash, ok := val.(*object.Hash)
+	if !ok {
+		return nil, newError(object.TypeMismatch, "cannot destructure %s as a hash", val.Type())
+	}
+
+	bindings := make([]binding, 0, len(pattern.Entries))
+	for _, entry := range pattern.Entries {
+		key := &object.String{Value: entry.Key.Value}
+		pair, ok := hash.Pairs[key.HashKey()]
+		switch {
+		case ok:
+			bindings = append(bindings, binding{entry.Name.Value, pair.Value})
+		case e.strictDestructuring:
+			return nil, newError(object.IndexError, "key not found: %s", key.Inspect())
+		default:
+			bindings = append(bindings, binding{entry.Name.Value, NULL})
+		}
+	}
+	return bindings, nil
+}
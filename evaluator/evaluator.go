@@ -3,129 +3,314 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/object"
+	"strings"
 )
 
 // initialise common objects once
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-// Eval recursively evaluates the given ast.Node and returns
-// an object
+// bounds of the integers shared by every evaluation rather than allocated
+// each time they are produced
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 255
+)
+
+var cachedIntegers = func() []*object.Integer {
+	integers := make([]*object.Integer, maxCachedInteger-minCachedInteger+1)
+	for i := range integers {
+		integers[i] = &object.Integer{Value: int64(i + minCachedInteger)}
+	}
+	return integers
+}()
+
+// DefaultMaxDepth is the maximum number of nested function calls
+// allowed by an Evaluator, unless configured otherwise
+const DefaultMaxDepth = 10000
+
+// Evaluator evaluates AST nodes, keeping track of the state needed to
+// enforce its configured limits
+type Evaluator struct {
+	maxDepth int // maximum number of nested function calls, 0 for no limit
+	depth    int // current number of nested function calls
+	maxSteps int // maximum number of evaluated nodes, 0 for no limit
+	steps    int // number of nodes evaluated so far
+
+	strictDestructuring bool // missing hash keys are an error rather than NULL
+
+	current   *object.Function                                     // function being applied, if any
+	tailCalls map[*ast.BlockStatement]map[*ast.CallExpression]bool // tail calls of each function body
+
+	ctx context.Context // cancels the evaluation when done
+}
+
+// Option configures an Evaluator
+type Option func(*Evaluator)
+
+// WithMaxDepth limits the number of nested function calls to n.
+// Once exceeded, evaluation stops with an error instead of overflowing
+// the stack. A limit of 0 or less disables the check
+func WithMaxDepth(n int) Option {
+	return func(e *Evaluator) {
+		e.maxDepth = n
+	}
+}
+
+// WithMaxSteps limits the total number of nodes evaluated to n, bounding
+// the amount of work a script can do. Once exceeded, evaluation stops
+// with an error. A limit of 0 or less, the default, disables the check
+func WithMaxSteps(n int) Option {
+	return func(e *Evaluator) {
+		e.maxSteps = n
+	}
+}
+
+// WithStrictDestructuring makes destructuring a hash fail with an error
+// when a key of the pattern is missing, instead of binding its name to NULL
+func WithStrictDestructuring() Option {
+	return func(e *Evaluator) {
+		e.strictDestructuring = true
+	}
+}
+
+// New returns an Evaluator configured with the given options
+func New(opts ...Option) *Evaluator {
+	e := &Evaluator{maxDepth: DefaultMaxDepth, ctx: context.Background()}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Eval evaluates the given ast.Node with a default Evaluator
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return EvalContext(context.Background(), node, env)
+}
+
+// EvalContext evaluates the given ast.Node with a default Evaluator,
+// stopping with an error once ctx is done
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return New().EvalContext(ctx, node, env)
+}
+
+// EvalContext evaluates the given ast.Node, stopping with an error once
+// ctx is done. The context is checked at every loop iteration and
+// function call, so long-running scripts can be cancelled
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	e.ctx = ctx
+	return e.Eval(node, env)
+}
+
+// checkContext returns an error if the evaluation's context is done
+func (e *Evaluator) checkContext() *object.Error {
+	if err := e.ctx.Err(); err != nil {
+		return newError(object.Cancelled, "evaluation cancelled: %s", err)
+	}
+	return nil
+}
+
+// Eval recursively evaluates the given ast.Node and returns
+// an object. Errors are located at the innermost node that raised them
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+
+	e.steps++
+	if e.maxSteps > 0 && e.steps > e.maxSteps {
+		result = newError(object.LimitExceeded, "maximum evaluation steps exceeded")
+	} else {
+		result = e.evalNode(node, env)
+	}
+
+	if err, ok := result.(*object.Error); ok && err.Pos.Line == 0 && node != nil {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+// evalNode evaluates node according to its type
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		if node.Pattern != nil {
+			return e.evalDestructuringLet(node, env)
+		}
+		// shadowing is only allowed in nested scopes
+		if _, ok := env.GetLocal(node.Name.Value); ok {
+			return newError(object.Redefinition, "identifier already defined: %s", node.Name.Value)
+		}
+		val := e.Eval(node.Value, env)
+		if isError(val) || isLoopControl(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.ConstStatement:
+		if _, ok := env.GetLocal(node.Name.Value); ok {
+			return newError(object.Redefinition, "identifier already defined: %s", node.Name.Value)
+		}
+		val := e.Eval(node.Value, env)
+		if isError(val) || isLoopControl(val) {
+			return val
+		}
+		env.SetImmutable(node.Name.Value, val)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	// Expressions
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return newInteger(node.Value)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
+	case *ast.PostfixExpression:
+		return evalPostfixExpression(node, env)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		right := Eval(node.Right, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+		left := e.Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
+	case *ast.TernaryExpression:
+		condition := e.Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return e.Eval(node.Consequence, env)
+		}
+		return e.Eval(node.Alternative, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+	case *ast.SwitchExpression:
+		return e.evalSwitchExpression(node, env)
+	case *ast.WhileExpression:
+		return e.evalLoop(node.Condition, node.Body, env, true)
+	case *ast.DoWhileExpression:
+		return e.evalLoop(node.Condition, node.Body, env, false)
+	case *ast.ForExpression:
+		return e.evalForExpression(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Env: env, Body: body}
+	case *ast.MacroLiteral:
+		// DefineMacros has taken out every macro that can be bound
+		return newError(object.MacroError, "macro literal outside a top-level let")
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		if isCallTo(node, "quote") {
+			return e.quote(node, env)
+		}
+		function := e.Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && (isError(args[0]) || isLoopControl(args[0])) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		if call, ok := e.asTailCall(node, function, args); ok {
+			return call
+		}
+		return e.applyFunction(function, args)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.CharLiteral:
+		return newInteger(int64(node.Value))
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && (isError(elements[0]) || isLoopControl(elements[0])) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := e.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return e.evalSliceExpression(node, env)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 	}
 	return nil
 }
 
 // Evaluate the root node of the program
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return newError(object.ControlFlowError, "%s outside loop", result.Inspect())
 		}
 	}
 	return result
 }
 
 // Evaluate a block statement
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -140,14 +325,25 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
+// newInteger returns an Integer holding value, sharing the cached one for
+// small values. Integers are never changed in place, so sharing is safe
+func newInteger(value int64) *object.Integer {
+	if value >= minCachedInteger && value <= maxCachedInteger {
+		return cachedIntegers[value-minCachedInteger]
+	}
+	return &object.Integer{Value: value}
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalTildePrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.UnknownOperator, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -172,12 +368,84 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	// If the element after the minus is not an integer, return NULL
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newError(object.UnknownOperator, "unknown operator: -%s", right.Type())
 	}
 	// Assert the type, and save the value
 	value := right.(*object.Integer).Value
+	// The most negative integer has no positive counterpart
+	if value == math.MinInt64 {
+		return newError(object.ArithmeticError, "integer overflow: -%d", value)
+	}
 	// Return an Integer object with the negative value
-	return &object.Integer{Value: -value}
+	return newInteger(-value)
+}
+
+// evaluate the bitwise complement of an integer
+// ~4
+func evalTildePrefixOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError(object.UnknownOperator, "unknown operator: ~%s", right.Type())
+	}
+	value := right.(*object.Integer).Value
+	return newInteger(^value)
+}
+
+// evaluate a postfix expression, rebinding its operand and
+// returning the operand's previous value
+// i++
+func evalPostfixExpression(node *ast.PostfixExpression, env *object.Environment) object.Object {
+	ident, ok := node.Left.(*ast.Identifier)
+	if !ok {
+		return newError(object.UnknownOperator, "invalid operand for %s: %s", node.Operator, node.Left.String())
+	}
+
+	scope, ok := env.Scope(ident.Value)
+	if !ok {
+		return newError(object.UnknownIdentifier, "identifier not found: "+ident.Value)
+	}
+	if mutable, _ := scope.IsMutable(ident.Value); !mutable {
+		return newError(object.ConstAssignment, "cannot assign to constant: "+ident.Value)
+	}
+
+	val, _ := scope.Get(ident.Value)
+	integer, ok := val.(*object.Integer)
+	if !ok {
+		return newError(object.UnknownOperator, "unknown operator: %s%s", val.Type(), node.Operator)
+	}
+
+	var operator string
+	switch node.Operator {
+	case "++":
+		operator = "+"
+	case "--":
+		operator = "-"
+	default:
+		return newError(object.UnknownOperator, "unknown operator: %s%s", val.Type(), node.Operator)
+	}
+	result, ok := object.CheckedArithmetic(operator, integer.Value, 1)
+	if !ok {
+		return newError(object.ArithmeticError, "integer overflow: %d%s", integer.Value, node.Operator)
+	}
+	scope.Set(ident.Value, newInteger(result))
+
+	return integer
+}
+
+// evalLogicalExpression evaluates && and ||, which only evaluate their
+// right operand when the left one does not decide the result. Like the
+// conditions of if, operands are tested for truthiness, and the operand
+// that decided the result is returned as is
+// e.g.
+// false && f() is false without calling f, and 0 || "none" is 0
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+	return e.Eval(node.Right, env)
 }
 
 // evaluate an infix expressions
@@ -188,15 +456,15 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equals(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeMismatch, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -206,14 +474,15 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	rightVal := right.(*object.Integer).Value
 
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+	case "+", "-", "*", "/", "%":
+		if (operator == "/" || operator == "%") && rightVal == 0 {
+			return newError(object.ArithmeticError, "division by zero")
+		}
+		result, ok := object.CheckedArithmetic(operator, leftVal, rightVal)
+		if !ok {
+			return newError(object.ArithmeticError, "integer overflow: %d %s %d", leftVal, operator, rightVal)
+		}
+		return newInteger(result)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -223,25 +492,141 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
 // Evaluate If Else expressions
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
+	// each branch gets its own scope, so it can shadow outer bindings
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.Eval(ie.Consequence, object.NewEnclosedEnvironment(env))
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.Eval(ie.Alternative, object.NewEnclosedEnvironment(env))
 	} else {
 		return NULL
 	}
 }
 
+// Evaluate Switch expressions. The body of the first case whose value
+// equals the subject runs in its own scope; the default body runs if
+// none does, and NULL is returned if there is no default
+func (e *Evaluator) evalSwitchExpression(se *ast.SwitchExpression, env *object.Environment) object.Object {
+	subject := e.Eval(se.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, c := range se.Cases {
+		value := e.Eval(c.Value, env)
+		if isError(value) {
+			return value
+		}
+		if object.Equals(subject, value) {
+			return e.Eval(c.Body, object.NewEnclosedEnvironment(env))
+		}
+	}
+
+	if se.Default != nil {
+		return e.Eval(se.Default, object.NewEnclosedEnvironment(env))
+	}
+	return NULL
+}
+
+// Evaluate While and DoWhile expressions, which check their condition
+// before or after each run of the body. The value of the loop is the
+// value of the last evaluated body, or NULL if the body never ran
+func (e *Evaluator) evalLoop(cond ast.Expression, body *ast.BlockStatement, env *object.Environment, checkFirst bool) object.Object {
+	var result object.Object = NULL
+
+	for first := true; ; first = false {
+		if err := e.checkContext(); err != nil {
+			return err
+		}
+
+		// a do-while body runs once before its condition is first checked
+		if checkFirst || !first {
+			condition := e.Eval(cond, env)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return result
+			}
+		}
+
+		// a fresh scope per iteration lets the body run its let statements again
+		evaluated := e.Eval(body, object.NewEnclosedEnvironment(env))
+		if evaluated == nil {
+			result = NULL
+			continue
+		}
+		switch evaluated.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+			return evaluated
+		case object.BREAK_OBJ:
+			return result
+		case object.CONTINUE_OBJ:
+			continue
+		}
+		result = evaluated
+	}
+}
+
+// Evaluate For expressions. The init statement binds its names in a scope
+// of the loop, which the update sees as well; the value of the loop is
+// the value of the last evaluated body, or NULL if the body never ran
+func (e *Evaluator) evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+	if fe.Init != nil {
+		if init := e.Eval(fe.Init, loopEnv); isError(init) {
+			return init
+		}
+	}
+
+	var result object.Object = NULL
+	for {
+		if err := e.checkContext(); err != nil {
+			return err
+		}
+
+		if fe.Condition != nil {
+			condition := e.Eval(fe.Condition, loopEnv)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return result
+			}
+		}
+
+		evaluated := e.Eval(fe.Body, object.NewEnclosedEnvironment(loopEnv))
+		if evaluated == nil {
+			evaluated = NULL
+		}
+		switch evaluated.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+			return evaluated
+		case object.BREAK_OBJ:
+			return result
+		case object.CONTINUE_OBJ:
+			// the update still runs before the next pass
+		default:
+			result = evaluated
+		}
+
+		if fe.Update != nil {
+			if update := e.Eval(fe.Update, loopEnv); isError(update) {
+				return update
+			}
+		}
+	}
+}
+
 // Determines whether an object is truthy or not
 // What does truthy mean to Monkey?
 func isTruthy(obj object.Object) bool {
@@ -257,8 +642,8 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 // returns whether an object is an error type
@@ -269,23 +654,53 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+// isLoopControl reports whether obj is a break or continue, which must
+// reach the enclosing loop rather than be used as a value
+func isLoopControl(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.BREAK_OBJ || obj.Type() == object.CONTINUE_OBJ
+	}
+	return false
+}
+
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
-	return newError("identifier not found: " + node.Value)
+	if builtin, ok := e.higherOrderBuiltin(node.Value); ok {
+		return builtin
+	}
+	return newError(object.UnknownIdentifier, "identifier not found: "+node.Value)
+}
+
+// evalAssignExpression rebinds an existing name in the scope that owns it
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	scope, ok := env.Scope(node.Name.Value)
+	if !ok {
+		return newError(object.UnknownIdentifier, "identifier not found: "+node.Name.Value)
+	}
+	if mutable, _ := scope.IsMutable(node.Name.Value); !mutable {
+		return newError(object.ConstAssignment, "cannot assign to constant: "+node.Name.Value)
+	}
+
+	val := e.Eval(node.Value, env)
+	if isError(val) || isLoopControl(val) {
+		return val
+	}
+
+	return scope.Set(node.Name.Value, val)
 }
 
 // Helper to evaluate expressions
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if isError(evaluated) || isLoopControl(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -293,25 +708,84 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		e.depth++
+		defer func() { e.depth-- }()
+		if e.maxDepth > 0 && e.depth > e.maxDepth {
+			return newError(object.LimitExceeded, "maximum call depth exceeded")
+		}
+		for {
+			if err := e.checkContext(); err != nil {
+				return err
+			}
+
+			required := len(fn.Parameters) - len(fn.Defaults)
+			if len(args) < required || len(args) > len(fn.Parameters) {
+				expected := fmt.Sprint(len(fn.Parameters))
+				if required != len(fn.Parameters) {
+					expected = fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+				}
+				return newError(object.ArgumentError, "wrong number of arguments: expected %s (%s), got %d",
+					expected, strings.Join(ast.ParameterList(fn.Parameters, fn.Defaults), ", "), len(args))
+			}
+
+			extendedEnv, err := e.extendFunctionEnv(fn, args)
+			if err != nil {
+				return err
+			}
+			evaluated := e.evalFunctionBody(fn, extendedEnv)
+
+			// a self-call in tail position re-enters the body in place
+			call, ok := evaluated.(*tailCall)
+			if !ok {
+				return evaluated
+			}
+			args = call.args
+		}
 	case *object.Builtin:
+		if err := checkArgs(fn, args); err != nil {
+			return err
+		}
 		return fn.Fn(args...)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(object.NotAFunction, "not a function: %s", fn.Type())
+	}
+}
+
+// evalFunctionBody evaluates the body of fn in env and returns the
+// value of the call
+func (e *Evaluator) evalFunctionBody(fn *object.Function, env *object.Environment) object.Object {
+	caller := e.current
+	e.current = fn
+	defer func() { e.current = caller }()
+
+	evaluated := e.Eval(fn.Body, env)
+	switch evaluated.(type) {
+	case *object.Break, *object.Continue:
+		return newError(object.ControlFlowError, "%s outside loop", evaluated.Inspect())
 	}
+	return unwrapReturnValue(evaluated)
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the parameters of fn to args. Omitted trailing
+// arguments take their default value, evaluated in the new environment so
+// that it can refer to the parameters before it
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+		val := e.Eval(fn.Defaults[param.Value], env)
+		if isError(val) {
+			return nil, val
+		}
+		env.Set(param.Value, val)
 	}
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -323,7 +797,7 @@ func unwrapReturnValue(obj object.Object) object.Object {
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -334,43 +808,140 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ:
+		return newError(object.IndexError, "string index must be INTEGER, got %s", index.Type())
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator  not supported: %s", left.Type())
+		return newError(object.IndexError, "index operator  not supported: %s", left.Type())
 	}
 }
 
+// evalArrayIndexExpression returns the element at index. Negative indices
+// count from the end of the array, so -1 is the last element.
+// Indices out of range return NULL
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
 	max := int64(len(arrayObject.Elements) - 1)
+	if idx < 0 {
+		idx += max + 1
+	}
 	if idx < 0 || idx > max {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+// evalStringIndexExpression returns the byte at index as a one character
+// string. Like len and slicing, indexing works on bytes rather than runes,
+// so a multi-byte character can't be retrieved by a single index.
+// Negative indices count from the end, indices out of range return NULL
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx := index.(*object.Integer).Value
+	max := int64(len(value) - 1)
+	if idx < 0 {
+		idx += max + 1
+	}
+	if idx < 0 || idx > max {
+		return NULL
+	}
+	return &object.String{Value: value[idx : idx+1]}
+}
+
+// evalSliceExpression returns the half-open range [low:high] of an array or
+// a string as a new object. Omitted bounds default to the start and the end,
+// negative bounds count from the end, and bounds out of range are clamped.
+// Strings are sliced by bytes, consistently with len
+func (e *Evaluator) evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case *object.String:
+		length = int64(len(left.Value))
+	default:
+		return newError(object.IndexError, "slice operator not supported: %s", left.Type())
+	}
+
+	low, err := e.evalSliceBound(node.Low, env, 0, length)
+	if err != nil {
+		return err
+	}
+	high, err := e.evalSliceBound(node.High, env, length, length)
+	if err != nil {
+		return err
+	}
+	if low > high {
+		low = high
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, high-low)
+		copy(elements, left.Elements[low:high])
+		return &object.Array{Elements: elements}
+	default:
+		return &object.String{Value: left.(*object.String).Value[low:high]}
+	}
+}
+
+// evalSliceBound evaluates a slice bound, returning def if it was omitted.
+// The result is clamped between 0 and length
+func (e *Evaluator) evalSliceBound(node ast.Expression, env *object.Environment, def, length int64) (int64, object.Object) {
+	if node == nil {
+		return def, nil
+	}
+
+	bound := e.Eval(node, env)
+	if isError(bound) {
+		return 0, bound
+	}
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError(object.IndexError, "slice bound must be INTEGER, got %s", bound.Type())
+	}
+
+	idx := integer.Value
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		return 0, nil
+	}
+	if idx > length {
+		return length, nil
+	}
+	return idx, nil
+}
+
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+		key := e.Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+		if !object.IsHashable(key) {
+			return newError(object.TypeMismatch, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.Eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
+		hashed := key.(object.Hashable).HashKey()
 		pairs[hashed] = object.HashPair{Key: key, Value: value}
 	}
 
@@ -380,12 +951,11 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+	if !object.IsHashable(index) {
+		return newError(object.TypeMismatch, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Pairs[index.(object.Hashable).HashKey()]
 	if !ok {
 		return NULL
 	}
@@ -1,11 +1,14 @@
 package evaluator_test
 
 import (
+	"context"
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -17,6 +20,13 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"10", 10},
 		{"-5", -5},
 		{"-10", -10},
+		{"~0", -1},
+		{"~5", -6},
+		{"~-1", 0},
+		{"~~7", 7},
+		{"'A'", 65},
+		{"'a' - 'A'", 32},
+		{"'\\n'", 10},
 		{"5 + 5 + 5 + 5 - 10", 10},
 		{"2 * 2 * 2 * 2 * 2", 32},
 		{"-50 + 100 + -50", 0},
@@ -28,6 +38,9 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"10 % 3", 1},
+		{"-10 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -127,10 +140,16 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (true) { 10 }", 10},
 		{"if (false) { 10 }", nil}, // should return NULL
 		{"if (1) { 10 }", 10},
+		{"if (0) { 10 }", 10},                            // 0 is truthy
+		{"if (if (false) { 1 }) { 10 } else { 20 }", 20}, // null is falsey
 		{"if (1 < 2) { 10 }", 10},
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 < 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 10},
+		{"if (1 > 2) { 10 } else if (2 < 1) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 < 1) { 20 }", nil},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -143,6 +162,30 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestIfConditionError(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"if (foobar) { 10 }", "identifier not found: foobar"},
+		{"if (foobar > 1) { 10 } else { 20 }", "identifier not found: foobar"},
+		{"if (1 + true) { 10 } else { 20 }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != evaluator.NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -195,6 +238,10 @@ func TestErrorHandling(t *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			`~"a"`,
+			"unknown operator: ~STRING",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -260,6 +307,170 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const a = 5; a;", 5},
+		{"const a = 5; let b = a * 2; b;", 10},
+		{"const a = 5; a = 10;", errorMessage("cannot assign to constant: a")},
+		{"const a = 5; a++;", errorMessage("cannot assign to constant: a")},
+		{"const a = 5; let f = fn() { a = 7; }; f();", errorMessage("cannot assign to constant: a")},
+		{"const a = 5; let a = 6;", errorMessage("identifier already defined: a")},
+		{"let a = 5; const a = 6;", errorMessage("identifier already defined: a")},
+		{"let a = 5; a = 10; a;", 10},
+		// a shadowing let in a nested scope is a new, mutable binding
+		{"const a = 5; let f = fn() { let a = 1; a = 2; a }; f() + a;", 7},
+		{"const a = 1 / 0;", errorMessage("division by zero")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestLetRedefinition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; let x = 2;", errorMessage("identifier already defined: x")},
+		{"let f = fn() { let y = 1; let y = 2; }; f()", errorMessage("identifier already defined: y")},
+		{"let f = fn(x) { let x = 2; }; f(1)", errorMessage("identifier already defined: x")},
+		// shadowing in nested scopes is allowed
+		{"let x = 1; if (true) { let x = 2; x }", 2},
+		{"let x = 1; if (true) { let x = 2; }; x", 1},
+		{"let x = 1; if (false) { 0 } else { let x = 3; x }", 3},
+		{"let x = 1; let f = fn() { let x = 2; x }; f() + x", 3},
+		{"let i = 0; let s = 0; while (i < 3) { let x = i; s = s + x; i++ }; s", 3},
+		{"let x = 1; if (true) { x = 2; }; x", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestArrayDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b, c] = [1, 2, 3]; a * 100 + b * 10 + c", 123},
+		{"let [head, ...tail] = [1, 2, 3]; head", 1},
+		{"let [head, ...tail] = [1, 2, 3]; tail", []int64{2, 3}},
+		{"let [a, b, ...rest] = [1, 2]; rest", []int64{}},
+		{"let [...all] = [1, 2]; all", []int64{1, 2}},
+		{"let arr = [1, 2]; let [...copy] = arr; copy[0] = 5; arr[0]", 1},
+		{"let [a, b] = [1]", errorMessage("wrong number of values to destructure: expected 2, got 1")},
+		{"let [a] = [1, 2]", errorMessage("wrong number of values to destructure: expected 1, got 2")},
+		{"let [a, b, ...c] = [1]", errorMessage("wrong number of values to destructure: expected at least 2, got 1")},
+		{"let [a] = 1", errorMessage("cannot destructure INTEGER as an array")},
+		{"let a = 1; let [a] = [2]", errorMessage("identifier already defined: a")},
+		{"let [a, a] = [1, 2]", errorMessage("identifier already defined: a")},
+		{"let a = 1; if (true) { let [a] = [2]; a }", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong number of elements for %q. want=%d, got=%d", tt.input, len(expected), len(arr.Elements))
+				continue
+			}
+			for i, want := range expected {
+				testIntegerObject(t, arr.Elements[i], want)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestHashDestructuring(t *testing.T) {
+	person := `let person = {"name": "Ann", "age": 30};`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{person + "let {name, age} = person; age", 30},
+		{person + "let {name, age} = person; name", "Ann"},
+		{person + "let {age: years} = person; years", 30},
+		{person + "let {email} = person; email", nil},
+		{person + "let {age: years} = person; age", errorMessage("identifier not found: age")},
+		{"let {a} = [1]", errorMessage("cannot destructure ARRAY as a hash")},
+		{person + "let age = 1; let {age} = person", errorMessage("identifier already defined: age")},
+		{person + "let {name, age: name} = person", errorMessage("identifier already defined: name")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong value for %q. want=%q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStrictHashDestructuring(t *testing.T) {
+	input := `let {name, email} = {"name": "Ann"};`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	e := evaluator.New(evaluator.WithStrictDestructuring())
+	testErrorObject(t, e.Eval(program, object.NewEnvironment()), "key not found: email")
+}
+
+func TestSourceRoundTrip(t *testing.T) {
+	inputs := []string{
+		`["a", "b", 1]`,
+		`{"name": "Ann", "tags": ["x", "y"], 1: true}`,
+		`fn(x, y = "s") { if (x > 1) { x } else { y } }`,
+	}
+
+	for _, input := range inputs {
+		evaluated := testEval(input)
+		source := object.Source(evaluated)
+
+		again := testEval(source)
+		if object.Source(again) != source {
+			t.Errorf("source of %q does not round trip. got=%q, then=%q", input, source, object.Source(again))
+		}
+		if _, ok := evaluated.(*object.Function); !ok && !object.Equals(evaluated, again) {
+			t.Errorf("%q evaluates to %s, its source %q to %s", input, evaluated.Inspect(), source, again.Inspect())
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2 };"
 	evaluated := testEval(input)
@@ -345,7 +556,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: got=2, want=1"},
 		{`len([1, 2])`, 2},
 		{`len([1 + 2, 2 * 4])`, 2},
 		{`len([true, "Hello, World!", 9999])`, 3},
@@ -372,6 +583,245 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestContainsAndIndexOfBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`contains([1, 2, 3], 2)`, true},
+		{`contains([1, 2, 3], 4)`, false},
+		{`contains([1, "2", [3]], [3])`, true},
+		{`contains([1, 2, 3], "2")`, false},
+		{`contains([], 1)`, false},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "donkey")`, false},
+		{`contains("monkey", "")`, true},
+		{`indexOf([1, 2, 3, 2], 2)`, 1},
+		{`indexOf([1, 2, 3], 4)`, -1},
+		{`indexOf([{"a": 1}, {"a": 2}], {"a": 2})`, 1},
+		{`indexOf("monkey", "key")`, 3},
+		{`indexOf("monkey", "x")`, -1},
+		{`contains(1, 1)`, errorMessage("first argument to `contains` must be ARRAY or STRING, got INTEGER")},
+		{`indexOf({}, 1)`, errorMessage("first argument to `indexOf` must be ARRAY or STRING, got HASH")},
+		{`indexOf("123", 2)`, errorMessage("item searched by `indexOf` in a STRING must be STRING, got INTEGER")},
+		{`contains([1])`, errorMessage("wrong number of arguments to `contains`: got=1, want=2")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestSortAndReverseBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{"sort([5, -1, 5, 0])", "[-1, 0, 5, 5]"},
+		{`sort(["pear", "apple", "fig"])`, "[apple, fig, pear]"},
+		{"sort([])", "[]"},
+		{"let a = [3, 1, 2]; sort(a); a", "[3, 1, 2]"},
+		{"sort([1, 20, 3], fn(a, b) { a > b })", "[20, 3, 1]"},
+		{`sort(["bb", "a", "ccc", "dd"], fn(a, b) { len(a) < len(b) })`, "[a, bb, dd, ccc]"},
+		{"reverse([1, 2, 3])", "[3, 2, 1]"},
+		{"reverse([])", "[]"},
+		{"let a = [1, 2]; reverse(a); a", "[1, 2]"},
+		{`sort([1, "a"])`, errorMessage("cannot sort mixed types: INTEGER and STRING")},
+		{"sort([true, false])", errorMessage("cannot sort elements of type BOOLEAN")},
+		{"sort([1, 2], fn(a, b) { a < c })", errorMessage("identifier not found: c")},
+		{"sort(1)", errorMessage("argument to `sort` must be ARRAY, got INTEGER")},
+		{"sort([1], 2)", errorMessage("argument to `sort` must be FUNCTION, got INTEGER")},
+		{`reverse("abc")`, errorMessage("argument to `reverse` must be ARRAY, got STRING")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. want=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`keys({"b": 2, "a": 1, "c": 3})`, "[a, b, c]"},
+		{`values({"b": 2, "a": 1, "c": 3})`, "[1, 2, 3]"},
+		{`keys({})`, "[]"},
+		{`values({true: [1], 2: "two"})`, "[two, [1]]"},
+		{`delete({"a": 1, "b": 2}, "a")`, `{b: 2}`},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a"); h["a"]`, "1"},
+		{`let h = {"a": 1}; delete(h, "z") == h`, "true"},
+		{`let h = {"a": 1}; let k = keys(h); len(keys(delete(h, "a"))) + len(k)`, "1"},
+		{`keys([1])`, errorMessage("argument to `keys` must be HASH, got ARRAY")},
+		{`values("a")`, errorMessage("argument to `values` must be HASH, got STRING")},
+		{`delete(1, 1)`, errorMessage("argument to `delete` must be HASH, got INTEGER")},
+		{`delete({"a": 1}, [1])`, errorMessage("unusable as hash key: ARRAY")},
+		{`delete({"a": 1})`, errorMessage("wrong number of arguments to `delete`: got=1, want=2")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. want=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestSetBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// the original hash is left untouched
+		{`let h = {"a": 1}; let g = set(h, "b", 2); [h, g]`, `[{"a": 1}, {"a": 1, "b": 2}]`},
+		{`let h = {"a": 1}; let g = set(h, "a", 2); [h, g]`, `[{"a": 1}, {"a": 2}]`},
+		{`set({}, 1, "one")[1]`, `"one"`},
+		{`set({}, true, [1])`, `{true: [1]}`},
+		{`set({}, [1], 1)`, errorMessage("unusable as hash key: ARRAY")},
+		{`set([], 1, 1)`, errorMessage("argument to `set` must be HASH, got ARRAY")},
+		{`set({}, 1)`, errorMessage("wrong number of arguments to `set`: got=2, want=3")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			// expected is the source of an equal value
+			if !object.Equals(evaluated, testEval(expected)) {
+				t.Errorf("wrong result for %s. want=%s, got=%s", tt.input, expected, object.Source(evaluated))
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"abs(-5)", 5},
+		{"abs(5)", 5},
+		{"abs(0)", 0},
+		{"min(3, 1, 2)", 1},
+		{"max(3, 1, 2)", 3},
+		{"min(7)", 7},
+		{"max(-3, -1, -2)", -1},
+		{"abs(-9223372036854775807 - 1)", errorMessage("integer overflow: abs(-9223372036854775808)")},
+		{"abs(true)", errorMessage("argument to `abs` must be INTEGER, got BOOLEAN")},
+		{"min()", errorMessage("wrong number of arguments to `min`: got=0, want=at least 1")},
+		{"max()", errorMessage("wrong number of arguments to `max`: got=0, want=at least 1")},
+		{`max(1, "2")`, errorMessage("arguments to `max` must be INTEGER, got STRING")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestTypePredicateBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"is_int(1)", true},
+		{`is_int("1")`, false},
+		{`is_string("a")`, true},
+		{"is_string(1)", false},
+		{"is_array([1])", true},
+		{`is_array({"a": 1})`, false},
+		{`is_hash({"a": 1})`, true},
+		{"is_hash([1])", false},
+		{"is_fn(fn(x) { x })", true},
+		{"is_fn(len)", true},
+		{"is_fn(map)", true},
+		{"is_fn(1)", false},
+		{"is_null(if (false) { 1 })", true},
+		{"is_null(0)", false},
+		{"is_bool(false)", true},
+		{"is_bool(0)", false},
+		{"is_int()", errorMessage("wrong number of arguments to `is_int`: got=0, want=1")},
+		{"is_bool(true, false)", errorMessage("wrong number of arguments to `is_bool`: got=2, want=1")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestRangeBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"range(3)", "[0, 1, 2]"},
+		{"range(0)", "[]"},
+		{"range(-2)", "[]"},
+		{"range(2, 5)", "[2, 3, 4]"},
+		{"range(5, 2)", "[]"},
+		{"range(0, 10, 3)", "[0, 3, 6, 9]"},
+		{"range(0, 9, 3)", "[0, 3, 6]"},
+		{"range(5, 0, -2)", "[5, 3, 1]"},
+		{"range(9223372036854775805, 9223372036854775807, 5)", "[9223372036854775805]"},
+		{"len(range(-9223372036854775807 - 1, 9223372036854775807, 9223372036854775807))", "3"},
+		{"reduce(range(1, 5), fn(acc, x) { acc * x }, 1)", "24"},
+		{"range(0, 3, 0)", errorMessage("step of `range` must not be 0")},
+		{"range(0, 3, -1)", errorMessage("step of `range` must be positive from 0 to 3, got -1")},
+		{`range("3")`, errorMessage("arguments to `range` must be INTEGER, got STRING")},
+		{"range()", errorMessage("wrong number of arguments to `range`: got=0, want=1 to 3")},
+		{"range(9223372036854775807)", errorMessage("`range` of 9223372036854775807 elements exceeds the limit of 10000000")},
+		{"range(-9223372036854775807 - 1, 9223372036854775807)", errorMessage("`range` of 18446744073709551615 elements exceeds the limit of 10000000")},
+		{"range(10000001)", errorMessage("`range` of 10000001 elements exceeds the limit of 10000000")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. want=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
 func TestTypeBuiltin(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -454,6 +904,22 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[-2]",
+			2,
+		},
+		{
+			"[1, 2, 3][-4]",
+			nil,
+		},
+		{
+			"[][-1]",
 			nil,
 		},
 	}
@@ -553,3 +1019,1300 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = 10;", 10},
+		{"let a = 5; let b = 0; a = b = 3; a + b;", 6},
+		{"let a = 5; if (true) { a = a + 1; }; a;", 6},
+		{"let a = 5; let set = fn() { a = 7; }; set(); a;", 7},
+		{"let a = 5; let f = fn(a) { a = 1; a }; f(3) + a;", 6},
+		{"b = 1;", "identifier not found: b"},
+		{"let a = 1; a = foo;", "identifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestWhileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i = i + 1; }; i;", 10},
+		{"let i = 0; while (i < 3) { i = i + 1; }", 3},
+		{"let i = 0; let sum = 0; while (i < 5) { i = i + 1; sum = sum + i; }; sum;", 15},
+		{"while (false) { 10 }", nil},
+		{"let i = 0; while (false) { i = 1; }; i;", 0},
+		{"let f = fn() { let i = 0; while (true) { i = i + 1; if (i > 4) { return i; } } }; f();", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (let i = 0; i < 10; i = i + 1) { sum = sum + i; }; sum;", 45},
+		{"let sum = 0; for (let i = 1; i < 5; i++) { sum = sum + i }", 10},
+		{"let i = 0; for (; i < 3; i++) { }; i;", 3},
+		{"let i = 0; for (i = 5; i < 3; i++) { }; i;", 5},
+		{"for (let i = 0; i < 0; i++) { i }", nil},
+		// break and continue, which still runs the update
+		{"let n = 0; for (;;) { n++; if (n == 4) { break; } }; n;", 4},
+		{"let sum = 0; for (let i = 0; i < 5; i++) { if (i == 2) { continue; } sum = sum + i; }; sum;", 8},
+		{"let f = fn() { for (let i = 0; ; i++) { if (i > 2) { return i; } } }; f();", 3},
+		// the loop variable does not outlive the loop
+		{"let i = 10; for (let i = 0; i < 3; i++) { }; i;", 10},
+		{"for (let i = 0; i < 3; i++) { let x = i; x }", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = x; i < 3; i++) { }", "identifier not found: x"},
+		{"for (let i = 0; i < y; i++) { }", "identifier not found: y"},
+		{"for (let i = 0; i < 3; z++) { }", "identifier not found: z"},
+		{"for (let i = 0; i < 3; i++) { }; i", "identifier not found: i"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDoWhileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// the body runs once even though the condition is false from the start
+		{"let i = 0; do { i = i + 1; } while (false); i;", 1},
+		{"let i = 0; do { i = i + 1; } while (i > 10); i;", 1},
+		{"let i = 0; do { i = i + 1; } while (i < 5); i;", 5},
+		{"let i = 0; do { i = i + 1 } while (i < 3)", 3},
+		{"do { } while (false)", nil},
+		{"let i = 0; do { i = i + 1; if (i == 2) { break; } } while (true); i;", 2},
+		{"let i = 0; do { i = i + 1; continue; } while (i < 4); i;", 4},
+		{"let f = fn() { do { return 7; } while (true) }; f();", 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBreakContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (true) { i = i + 1; if (i == 3) { break; } }; i;", 3},
+		{"let i = 0; while (i < 10) { if (i == 4) { break; } i = i + 1; }; i;", 4},
+		{
+			"let i = 0; let sum = 0; while (i < 5) { i = i + 1; if (i == 3) { continue; } sum = sum + i; }; sum;",
+			12,
+		},
+		{"break;", "break outside loop"},
+		{"if (true) { continue; }", "continue outside loop"},
+		{"let f = fn() { break; }; while (true) { f(); }", "break outside loop"},
+		// break and continue reach the loop from inside expressions
+		{"let i = 0; while (i < 100) { i++; let x = if (i == 3) { break; }; }; i;", 3},
+		{"let i = 0; while (i < 100) { i++; let [x] = if (i == 3) { break; } else { [i] }; }; i;", 3},
+		{"let i = 0; let x = 0; while (i < 100) { i++; x = if (i == 3) { break; } else { i }; }; x * 10 + i;", 23},
+		{"let i = 0; while (i < 100) { i++; [1, if (i == 3) { break; }]; }; i;", 3},
+		{"let id = fn(x) { x }; let i = 0; while (i < 100) { i++; id(if (i == 3) { break; }); }; i;", 3},
+		{
+			"let i = 0; let sum = 0; while (i < 5) { i++; let x = if (i == 3) { continue; } else { i }; sum = sum + x; }; sum;",
+			12,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestPostfixExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; i++;", 0},
+		{"let i = 0; i++; i;", 1},
+		{"let i = 0; i--; i;", -1},
+		{"let i = 5; i++ + i;", 11},
+		{"let i = 0; let inc = fn() { i++ }; inc(); inc(); i;", 2},
+		{"let i = 0; while (i < 3) { i++ }; i;", 3},
+		{"5--3", 8},
+		{"let i = 5; (i + 1)--i", 11},
+		{"j++;", "identifier not found: j"},
+		{`let s = "a"; s++;`, "unknown operator: STRING++"},
+		{"5++;", "invalid operand for ++: 5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	input := "let f = fn(x) { 1 + f(x + 1) }; f(0);"
+
+	tests := []struct {
+		name      string
+		evaluator *evaluator.Evaluator
+	}{
+		{"default", evaluator.New()},
+		{"custom", evaluator.New(evaluator.WithMaxDepth(50))},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		evaluated := tt.evaluator.Eval(program, object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.name, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "maximum call depth exceeded" {
+			t.Errorf("%s: wrong error message. got=%q", tt.name, errObj.Message)
+		}
+	}
+}
+
+func TestMaxCallDepthAllowsShallowRecursion(t *testing.T) {
+	input := `
+let count = fn(n) { if (n == 0) { return 0; } 1 + count(n - 1) };
+count(20);`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	e := evaluator.New(evaluator.WithMaxDepth(21))
+	testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 20)
+}
+
+func TestTailCallOptimization(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) }; sum(100000, 0)", 5000050000},
+		{"let sum = fn(n, acc) { if (n == 0) { acc } else { return sum(n - 1, acc + n); } }; sum(100000, 0)", 5000050000},
+		{"let sum = fn(n, acc = 0) { n == 0 ? acc : sum(n - 1, acc + n) }; sum(100000)", 5000050000},
+		{"let count = fn(n) { while (true) { if (n == 0) { return 0; } return count(n - 1); } }; count(100000)", 0},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := evaluator.New(evaluator.WithMaxDepth(100))
+		testIntegerObject(t, e.Eval(program, object.NewEnvironment()), tt.expected)
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	input := "let i = 0; while (true) { i = i + 1; }"
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	e := evaluator.New(evaluator.WithMaxSteps(1000))
+	evaluated := e.Eval(program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "maximum evaluation steps exceeded" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestMaxStepsAllowsBoundedWork(t *testing.T) {
+	input := "let i = 0; while (i < 10) { i = i + 1; }; i;"
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	e := evaluator.New(evaluator.WithMaxSteps(1000))
+	testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 10)
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	input := "let i = 0; while (true) { i = i + 1; }"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	done := make(chan object.Object)
+	go func() {
+		done <- evaluator.EvalContext(ctx, program, object.NewEnvironment())
+	}()
+
+	select {
+	case evaluated := <-done:
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+		}
+		if errObj.Message != "evaluation cancelled: context canceled" {
+			t.Errorf("wrong error message. got=%q", errObj.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("evaluation did not stop after the context was cancelled")
+	}
+}
+
+func TestEvalContextCancelledFunctionCall(t *testing.T) {
+	input := "let f = fn() { 1 }; f();"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	evaluated := evaluator.EvalContext(ctx, program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "evaluation cancelled: context canceled" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"-9223372036854775807 + -2", "integer overflow: -9223372036854775807 + -2"},
+		{"9223372036854775807 * 2", "integer overflow: 9223372036854775807 * 2"},
+		{"4611686018427387904 * -3", "integer overflow: 4611686018427387904 * -3"},
+		{"let min = -9223372036854775807 - 1; min * -1", "integer overflow: -9223372036854775808 * -1"},
+		{"let min = -9223372036854775807 - 1; -min", "integer overflow: --9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min / -1", "integer overflow: -9223372036854775808 / -1"},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775808},
+		{"4611686018427387904 * -2", -9223372036854775808},
+		{"-(9223372036854775807)", -9223372036854775807},
+		{"let i = 9223372036854775807; i++; i", "integer overflow: 9223372036854775807++"},
+		{"let i = -9223372036854775807 - 1; i--; i", "integer overflow: -9223372036854775808--"},
+		{"let i = 9223372036854775806; i++; i", 9223372036854775807},
+		{"let i = -9223372036854775807; i--; i", -9223372036854775808},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []string{"5 / 0", "5 % 0", "let zero = 0; 1 + 10 / zero"}
+
+	for _, input := range tests {
+		evaluated := testEval(input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "division by zero" {
+			t.Errorf("wrong error message for %q. got=%q", input, errObj.Message)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int64{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][:]", []int64{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][1:10]", []int64{2, 3, 4}},
+		{"[1, 2, 3, 4][-10:1]", []int64{1}},
+		{"[1, 2, 3, 4][3:1]", []int64{}},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[3:]`, "lo"},
+		{`"hello"[4:100]`, "o"},
+		{`"hello"[-3:-1]`, "ll"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("%s: object is not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("%s: wrong num of elements. want=%d, got=%d",
+					tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, array.Elements[i], el)
+			}
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("%s: String has wrong value. want=%q, got=%q", tt.input, expected, str.Value)
+			}
+		}
+	}
+}
+
+func TestSliceErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5[1:2]", "slice operator not supported: INTEGER"},
+		{`[1, 2]["a":]`, "slice bound must be INTEGER, got STRING"},
+		{"[1, 2][:foo]", "identifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello"[0]`, "h"},
+		{`"hello"[1]`, "e"},
+		{`"hello"[4]`, "o"},
+		{`"hello"[-1]`, "o"},
+		{`let s = "abc"; s[1 + 1]`, "c"},
+		{`"hello"[5]`, nil},
+		{`"hello"[-6]`, nil},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != expected {
+			t.Errorf("%s: String has wrong value. want=%q, got=%q", tt.input, expected, str.Value)
+		}
+	}
+}
+
+func TestStringIndexNonInteger(t *testing.T) {
+	evaluated := testEval(`"hello"["a"]`)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "string index must be INTEGER, got STRING" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	testIntegerObject(t, testEval("let café = 5; let 数 = 2; café * 数;"), 10)
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", []int64{2, 4, 6}},
+		{"map([], fn(x) { x * 2 })", []int64{}},
+		{`map(["a", "bc"], len)`, []int64{1, 2}},
+		{"filter([1, 2, 3, 4, 5, 6], fn(x) { x % 2 == 0 })", []int64{2, 4, 6}},
+		{"filter([1, 2, 3], fn(x) { false })", []int64{}},
+		{"reduce([1, 2, 3, 4], fn(acc, x) { acc + x }, 0)", 10},
+		{"reduce([], fn(acc, x) { acc + x }, 5)", 5},
+		{"reduce([1, 2, 3], fn(acc, x) { push(acc, x * x) }, [])", []int64{1, 4, 9}},
+		{"let double = fn(x) { x * 2 }; reduce(map([1, 2], double), fn(a, b) { a + b }, 0)", 6},
+		{"map([1, 2])", "wrong number of arguments to `map`: got=1, want=2"},
+		{"reduce([1, 2], fn(a, b) { a })", "wrong number of arguments to `reduce`: got=2, want=3"},
+		{"map(1, fn(x) { x })", "argument to `map` must be ARRAY, got INTEGER"},
+		{"filter([1], 1)", "argument to `filter` must be FUNCTION, got INTEGER"},
+		{"reduce([1], \"f\", 0)", "argument to `reduce` must be FUNCTION, got STRING"},
+		{"map([1, true], fn(x) { -x })", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("%s: object is not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("%s: wrong num of elements. want=%d, got=%d",
+					tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, array.Elements[i], el)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestSplitJoinBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`split("a,b,c", ",")`, []string{"a", "b", "c"}},
+		{`split("abc", "")`, []string{"a", "b", "c"}},
+		{`split("abc", ";")`, []string{"abc"}},
+		{`split("", ",")`, []string{""}},
+		{`join(["a", "b"], "-")`, "a-b"},
+		{`join([], "-")`, ""},
+		{`join(split("a b c", " "), "")`, "abc"},
+		{`split("a")`, errorMessage("wrong number of arguments to `split`: got=1, want=2")},
+		{`split(1, ",")`, errorMessage("argument to `split` must be STRING, got INTEGER")},
+		{`split("a", 1)`, errorMessage("separator for `split` must be STRING, got INTEGER")},
+		{`join("a", "-")`, errorMessage("argument to `join` must be ARRAY, got STRING")},
+		{`join(["a", 1], "-")`, errorMessage("elements joined by `join` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case []string:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("%s: object is not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("%s: wrong num of elements. want=%d, got=%d",
+					tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testStringObject(t, array.Elements[i], el)
+			}
+		case string:
+			testStringObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+// errorMessage marks an expected value as the message of an error object
+type errorMessage string
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int("42")`, 42},
+		{`int("-7")`, -7},
+		{`int(" 12 ")`, 12},
+		{`int(3)`, 3},
+		{`int("4" + "2") + 1`, 43},
+		{`int("abc")`, errorMessage(`could not parse "abc" as integer`)},
+		{`int("1.5")`, errorMessage(`could not parse "1.5" as integer`)},
+		{`int("")`, errorMessage(`could not parse "" as integer`)},
+		{`int(true)`, errorMessage("argument to `int` not supported, got BOOLEAN")},
+		{`int("1", "2")`, errorMessage("wrong number of arguments to `int`: got=2, want=1")},
+		{`str(42)`, "42"},
+		{`str(-1)`, "-1"},
+		{`str(true)`, "true"},
+		{`str([1, 2, 3])`, "[1, 2, 3]"},
+		{`str("hi")`, "hi"},
+		{`str(1) + str(2)`, "12"},
+		{`str()`, errorMessage("wrong number of arguments to `str`: got=0, want=1")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestBuiltinsListing(t *testing.T) {
+	list := evaluator.Builtins()
+
+	found := map[string]*object.Builtin{}
+	for i, builtin := range list {
+		if i > 0 && list[i-1].Name >= builtin.Name {
+			t.Errorf("builtins not sorted by name: %q before %q", list[i-1].Name, builtin.Name)
+		}
+		if builtin.Fn == nil {
+			t.Errorf("builtin %q has no function", builtin.Name)
+		}
+		found[builtin.Name] = builtin
+	}
+
+	tests := []struct {
+		name    string
+		minArgs int
+		maxArgs int
+	}{
+		{"len", 1, 1},
+		{"push", 2, 2},
+		{"print", 0, object.VARIADIC},
+		{"reduce", 3, 3},
+	}
+
+	for _, tt := range tests {
+		builtin, ok := found[tt.name]
+		if !ok {
+			t.Errorf("builtin %q not listed", tt.name)
+			continue
+		}
+		if builtin.MinArgs != tt.minArgs || builtin.MaxArgs != tt.maxArgs {
+			t.Errorf("builtin %q has wrong arity. want=%d..%d, got=%d..%d",
+				tt.name, tt.minArgs, tt.maxArgs, builtin.MinArgs, builtin.MaxArgs)
+		}
+	}
+}
+
+func TestBuiltinArityMessages(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`len()`, "wrong number of arguments to `len`: got=0, want=1"},
+		{`len("a", "b")`, "wrong number of arguments to `len`: got=2, want=1"},
+		{`push([1])`, "wrong number of arguments to `push`: got=1, want=2"},
+		{`push([1], 2, 3)`, "wrong number of arguments to `push`: got=3, want=2"},
+		{`first([1], [2])`, "wrong number of arguments to `first`: got=2, want=1"},
+		{`filter([1])`, "wrong number of arguments to `filter`: got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expectedMessage)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(1 + 2))`, `3`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quoted = quote(4 + 4); quote(unquote(4 + 4) + unquote(quoted))`,
+			`(8 + (4 + 4))`},
+	}
+
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`quote()`, "wrong number of arguments to `quote`: got=0, want=1"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments to `unquote`: got=2, want=1"},
+		{`quote(unquote(foobar))`, "identifier not found: foobar"},
+		{`quote(unquote([1]))`, "cannot unquote ARRAY"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expectedMessage)
+	}
+}
+
+func testQuoteObject(t *testing.T, obj object.Object, expected string) bool {
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Errorf("expected *object.Quote. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if quote.Node == nil {
+		t.Errorf("quote.Node is nil")
+		return false
+	}
+	if quote.Node.String() != expected {
+		t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), expected)
+		return false
+	}
+	return true
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	evaluator.DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameters wrong. got=%q", macro.Parameters)
+	}
+
+	expectedBody := "(x + y)"
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		evaluator.DefineMacros(program, env)
+		expanded, err := evaluator.ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Message)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q",
+				expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestMacroLiteralOutsideLet(t *testing.T) {
+	for _, input := range []string{
+		"macro(x) { x } + 1",
+		"let f = fn() { let m = macro(x) { x }; m }; f()",
+		"[macro() { quote(1) }]",
+	} {
+		testErrorObject(t, testEval(input), "macro literal outside a top-level let")
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			`let m = macro(a) { quote(a) }; m(1, 2);`,
+			"wrong number of arguments to macro `m`: got=2, want=1",
+		},
+		{
+			`let m = macro() { 1 }; m();`,
+			"macro `m` must return a QUOTE, got INTEGER",
+		},
+		{
+			`let m = macro() { quote(unquote(x)) }; m();`,
+			"identifier not found: x",
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		evaluator.DefineMacros(program, env)
+		_, err := evaluator.ExpandMacros(program, env)
+		testErrorObject(t, err, tt.expectedMessage)
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func TestSingletons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"true", evaluator.TRUE},
+		{"false", evaluator.FALSE},
+		{"1 < 2", evaluator.TRUE},
+		{"!true", evaluator.FALSE},
+		{"if (false) { 1 }", evaluator.NULL},
+		{`first([])`, evaluator.NULL},
+	}
+
+	for _, tt := range tests {
+		first, second := testEval(tt.input), testEval(tt.input)
+		if first != tt.expected || second != tt.expected {
+			t.Errorf("%q did not evaluate to the singleton %s. got=%p and %p, want=%p",
+				tt.input, tt.expected.Inspect(), first, second, tt.expected)
+		}
+	}
+}
+
+func TestEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, true},
+		{`[] != []`, false},
+		{`{"a": 1, 2: true} == {2: true, "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`let n = if (false) { 1 }; n == if (false) { 2 }`, true},
+		{`let n = if (false) { 1 }; n != n`, false},
+		{`let f = fn(x) { x }; f == f`, true},
+		{`fn(x) { x } == fn(x) { x }`, false},
+		{`len == len`, true},
+		{`1 == "1"`, false},
+		{`1 != "1"`, true},
+		{`true == 1`, false},
+		{`[1] == {1: 1}`, false},
+		{`"" == if (false) { 1 }`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"let x = 1;\n  foobar;", "2:3"},
+		{"let f = fn() {\n  1 + y\n};\nf()", "2:7"},
+		{"1 + true", "1:1"},
+		{"let a = [1];\na[\"b\"]", "2:1"},
+		{"len(1, 2)", "1:1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%s, got=%s",
+				tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedKind object.ErrorKind
+	}{
+		{"5 + true", object.TypeMismatch},
+		{"-true", object.UnknownOperator},
+		{`"a" - "b"`, object.UnknownOperator},
+		{"foobar", object.UnknownIdentifier},
+		{"let x = 1; let x = 2;", object.Redefinition},
+		{"const x = 1; x = 2;", object.ConstAssignment},
+		{"5(1)", object.NotAFunction},
+		{`len(1)`, object.ArgumentError},
+		{`len()`, object.ArgumentError},
+		{`"abc"["a"]`, object.IndexError},
+		{`{"a": 1}[fn(x) { x }]`, object.TypeMismatch},
+		{`{fn(x) { x }: 1}`, object.TypeMismatch},
+		{`set({}, [1], 1)`, object.TypeMismatch},
+		{`delete({"a": 1}, [1])`, object.TypeMismatch},
+		{"1 / 0", object.ArithmeticError},
+		{"9223372036854775807 + 1", object.ArithmeticError},
+		{"break", object.ControlFlowError},
+		{"let f = fn() { 1 + f() }; f()", object.LimitExceeded},
+		{"quote()", object.MacroError},
+		{"macro(x) { x } + 1", object.MacroError},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind for %q. expected=%s, got=%s",
+				tt.input, tt.expectedKind, errObj.Kind)
+		}
+	}
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`switch (2) { case 1 { 10 } case 2 { 20 } default { 30 } }`, 20},
+		{`switch (5) { case 1 { 10 } case 2 { 20 } default { 30 } }`, 30},
+		{`switch (5) { case 1 { 10 } }`, nil},
+		{`let x = 3; switch (x) { case 1 + 2 { x * 2 } }`, 6},
+		{`switch ("b") { case "a" { 1 } case "b" { 2 } }`, 2},
+		{`switch ([1, 2]) { case [1, 2] { 1 } default { 2 } }`, 1},
+		{`switch (1) { case "1" { 1 } default { 2 } }`, 2},
+		// only the first matching case runs
+		{`let n = 0; switch (1) { case 1 { n = n + 1 } case 1 { n = n + 10 } }; n`, 1},
+		{`let f = fn(x) { switch (x) { case 1 { return 10; } }; 20 }; f(1)`, 10},
+		{`switch (y) { case 1 { 1 } }`, errorMessage("identifier not found: y")},
+		{`switch (1) { case y { 1 } }`, errorMessage("identifier not found: y")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTernaryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true ? 1 : 2", 1},
+		{"false ? 1 : 2", 2},
+		{"1 < 2 ? 10 : 20", 10},
+		{"if (false) { 1 } ? 1 : 2", 2}, // null is falsey
+		{"0 ? 1 : 2", 1},                // 0 is truthy
+		{"let x = 5; x > 3 ? x * 2 : x", 10},
+		{"let n = 2; n == 1 ? 10 : n == 2 ? 20 : 30", 20},
+		{"let n = 3; n == 1 ? 10 : n == 2 ? 20 : 30", 30},
+		{"true ? false ? 1 : 2 : 3", 2},
+		// only the chosen branch is evaluated
+		{"true ? 1 : foobar", 1},
+		{"foobar ? 1 : 2", errorMessage("identifier not found: foobar")},
+		{"false ? 1 : foobar", errorMessage("identifier not found: foobar")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	// touch counts the operands evaluated
+	touch := "let calls = 0; let touch = fn(x) { calls++; x }; "
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"true || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"false || true && false", false},
+		// the deciding operand is returned as is
+		{"1 && 2", 2},
+		{"0 || 2", 0}, // 0 is truthy
+		{"if (false) { 1 } || 5", 5},
+		{"if (false) { 1 } && 5", nil},
+		// the right operand is only evaluated when it decides the result
+		{"false && len(1)", false},
+		{"true || len(1)", true},
+		{"true && len(1)", errorMessage("argument to `len` not supported, got INTEGER")},
+		{"false || len(1)", errorMessage("argument to `len` not supported, got INTEGER")},
+		{"foobar && true", errorMessage("identifier not found: foobar")},
+		{touch + "false && touch(true); true || touch(false); calls", 0},
+		{touch + "true && touch(true); false || touch(false); calls", 2},
+		{touch + "touch(false) && touch(true) || touch(true); calls", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBoundBuiltins(t *testing.T) {
+	testIntegerObject(t, testEval(`let l = len; l("four")`), 4)
+	testIntegerObject(t, testEval(`let apply = fn(f, x) { f(x) }; apply(len, [1, 2])`), 2)
+
+	evaluated := testEval(`let l = len; l`)
+	if evaluated.Type() != object.BUILTIN_OBJ || evaluated.Inspect() != "builtin function" {
+		t.Errorf("bound builtin wrong. got=%s (%s)", evaluated.Type(), evaluated.Inspect())
+	}
+}
+
+func TestDefaultParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let add = fn(x, y = 10) { x + y }; add(1)", 11},
+		{"let add = fn(x, y = 10) { x + y }; add(1, 2)", 3},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f()", 12},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f(3)", 32},
+		{"let f = fn(x, y = x * 2) { y }; f(4)", 8},
+		{"let n = 5; let f = fn(x = n) { x }; let n2 = 6; f()", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testErrorObject(t, testEval("let f = fn(x = y) { x }; f()"), "identifier not found: y")
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> double |> inc", 11},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> inc |> double", 12},
+		{"[1, 2, 3, 4] |> filter(fn(x) { x % 2 == 0 }) |> map(fn(x) { x * 10 }) |> len", 2},
+		{"[1, 2, 3] |> reduce(fn(acc, x) { acc + x }, 0)", 6},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionArityErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let add = fn(x, y) { x + y }; add(1, 2, 3)", "wrong number of arguments: expected 2 (x, y), got 3"},
+		{"let add = fn(x, y) { x + y }; add(1)", "wrong number of arguments: expected 2 (x, y), got 1"},
+		{"fn() { 1 }(1)", "wrong number of arguments: expected 0 (), got 1"},
+		{"map([1], fn(a, b) { a })", "wrong number of arguments: expected 2 (a, b), got 1"},
+		{"fn(x, y = 10) { x }()", "wrong number of arguments: expected 1 to 2 (x, y = 10), got 0"},
+		{"fn(x, y = 10) { x }(1, 2, 3)", "wrong number of arguments: expected 1 to 2 (x, y = 10), got 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testErrorObject(t, evaluated, tt.expectedMessage)
+
+		if errObj, ok := evaluated.(*object.Error); ok && errObj.Kind != object.ArgumentError {
+			t.Errorf("wrong error kind. want=%s, got=%s", object.ArgumentError, errObj.Kind)
+		}
+	}
+}
+
+func TestSmallIntegersAreShared(t *testing.T) {
+	tests := []struct {
+		left   string
+		right  string
+		shared bool
+	}{
+		{"1 + 1", "2", true},
+		{"-128", "0 - 128", true},
+		{"len([1, 2, 3])", "3", true},
+		{"255", "254 + 1", true},
+		{"256", "255 + 1", false},
+		{"-129", "-128 - 1", false},
+	}
+
+	for _, tt := range tests {
+		left := testEval(tt.left)
+		right := testEval(tt.right)
+		if (left == right) != tt.shared {
+			t.Errorf("%s and %s shared=%t, want=%t", tt.left, tt.right, left == right, tt.shared)
+		}
+	}
+}
+
+func BenchmarkSmallIntegerArithmetic(b *testing.B) {
+	program := parser.New(lexer.New(`
+		let i = 0;
+		while (i < 100) { i = (i * 3 + 7) % 200; i++; }
+	`)).ParseProgram()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		evaluator.Eval(program, object.NewEnvironment())
+	}
+}
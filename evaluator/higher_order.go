@@ -0,0 +1,166 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"sort"
+)
+
+// names of the builtins returned by higherOrderBuiltin
+var higherOrderBuiltins = []string{"map", "filter", "reduce", "sort"}
+
+// higherOrderBuiltin returns the builtin named name bound to e, if there
+// is one. These builtins take monkey functions as arguments, so they need
+// the Evaluator to call them back
+func (e *Evaluator) higherOrderBuiltin(name string) (*object.Builtin, bool) {
+	builtin := &object.Builtin{Name: name}
+
+	switch name {
+	case "map":
+		builtin.MinArgs, builtin.MaxArgs, builtin.Fn = 2, 2, e.builtinMap
+	case "filter":
+		builtin.MinArgs, builtin.MaxArgs, builtin.Fn = 2, 2, e.builtinFilter
+	case "reduce":
+		builtin.MinArgs, builtin.MaxArgs, builtin.Fn = 3, 3, e.builtinReduce
+	case "sort":
+		builtin.MinArgs, builtin.MaxArgs, builtin.Fn = 1, 2, e.builtinSort
+	default:
+		return nil, false
+	}
+
+	return builtin, true
+}
+
+// builtinMap implements map(arr, fn), returning a new array with fn
+// applied to each element
+func (e *Evaluator) builtinMap(args ...object.Object) object.Object {
+	arr, fn, err := higherOrderArgs("map", args)
+	if err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, 0, len(arr.Elements))
+	for _, el := range arr.Elements {
+		result := e.applyFunction(fn, []object.Object{el})
+		if isError(result) {
+			return result
+		}
+		elements = append(elements, result)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// builtinFilter implements filter(arr, fn), returning a new array with
+// the elements for which fn is truthy
+func (e *Evaluator) builtinFilter(args ...object.Object) object.Object {
+	arr, fn, err := higherOrderArgs("filter", args)
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, el := range arr.Elements {
+		result := e.applyFunction(fn, []object.Object{el})
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			elements = append(elements, el)
+		}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// builtinReduce implements reduce(arr, fn, init), folding the elements into
+// an accumulator starting from init and calling fn(accumulator, element)
+// for each element
+func (e *Evaluator) builtinReduce(args ...object.Object) object.Object {
+	arr, fn, err := higherOrderArgs("reduce", args)
+	if err != nil {
+		return err
+	}
+
+	acc := args[2]
+	for _, el := range arr.Elements {
+		acc = e.applyFunction(fn, []object.Object{acc, el})
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// builtinSort implements sort(arr) and sort(arr, less), returning a new
+// array with the elements in ascending order. Without less the elements
+// must be all integers or all strings; less(a, b) is truthy when a goes
+// before b. The sort is stable
+func (e *Evaluator) builtinSort(args ...object.Object) object.Object {
+	if len(args) == 2 {
+		arr, less, err := higherOrderArgs("sort", args)
+		if err != nil {
+			return err
+		}
+		elements := append([]object.Object{}, arr.Elements...)
+
+		var failed object.Object
+		sort.SliceStable(elements, func(i, j int) bool {
+			if failed != nil {
+				return false
+			}
+			result := e.applyFunction(less, []object.Object{elements[i], elements[j]})
+			if isError(result) {
+				failed = result
+				return false
+			}
+			return isTruthy(result)
+		})
+		if failed != nil {
+			return failed
+		}
+		return &object.Array{Elements: elements}
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.ArgumentError, "argument to `sort` must be ARRAY, got %s", args[0].Type())
+	}
+	elements := append([]object.Object{}, arr.Elements...)
+	if len(elements) == 0 {
+		return &object.Array{Elements: elements}
+	}
+
+	kind := elements[0].Type()
+	for _, el := range elements {
+		if el.Type() != kind {
+			return newError(object.TypeMismatch, "cannot sort mixed types: %s and %s", kind, el.Type())
+		}
+	}
+	switch kind {
+	case object.INTEGER_OBJ:
+		sort.SliceStable(elements, func(i, j int) bool {
+			return elements[i].(*object.Integer).Value < elements[j].(*object.Integer).Value
+		})
+	case object.STRING_OBJ:
+		sort.SliceStable(elements, func(i, j int) bool {
+			return elements[i].(*object.String).Value < elements[j].(*object.String).Value
+		})
+	default:
+		return newError(object.TypeMismatch, "cannot sort elements of type %s", kind)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// higherOrderArgs validates the arguments of a builtin taking an array
+// and a function as its first two arguments
+func higherOrderArgs(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, nil, newError(object.ArgumentError, "argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	switch args[1].(type) {
+	case *object.Function, *object.Builtin:
+		return arr, args[1], nil
+	default:
+		return nil, nil, newError(object.ArgumentError, "argument to `%s` must be FUNCTION, got %s", name, args[1].Type())
+	}
+}
@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// DefineMacros binds every top level `let name = macro(...) {...}` statement
+// of program in env and removes those statements from program
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := program.Statements[:0]
+
+	for _, statement := range program.Statements {
+		if !isMacroDefinition(statement) {
+			statements = append(statements, statement)
+			continue
+		}
+		addMacro(statement, env)
+	}
+
+	program.Statements = statements
+}
+
+// isMacroDefinition reports whether statement binds a macro literal
+func isMacroDefinition(statement ast.Statement) bool {
+	letStatement, ok := statement.(*ast.LetStatement)
+	if !ok || letStatement.Name == nil {
+		return false
+	}
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(statement ast.Statement, env *object.Environment) {
+	letStatement := statement.(*ast.LetStatement)
+	macroLiteral := letStatement.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Body:       macroLiteral.Body,
+		Env:        env,
+	}
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// ExpandMacros replaces every call to a macro defined in env with the
+// quoted node the macro returns. Macro arguments are passed unevaluated,
+// each wrapped in an *object.Quote
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var err *object.Error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+		macro, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+		if len(call.Arguments) != len(macro.Parameters) {
+			err = newError(object.MacroError, "wrong number of arguments to macro `%s`: got=%d, want=%d",
+				call.Function.String(), len(call.Arguments), len(macro.Parameters))
+			err.Pos = call.Pos()
+			return node
+		}
+
+		evaluated := unwrapReturnValue(New().Eval(macro.Body, extendMacroEnv(macro, call)))
+		if isError(evaluated) {
+			err = evaluated.(*object.Error)
+			return node
+		}
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			err = newError(object.MacroError, "macro `%s` must return a QUOTE, got %s",
+				call.Function.String(), typeOf(evaluated))
+			err.Pos = call.Pos()
+			return node
+		}
+		return quote.Node
+	})
+	if err != nil {
+		return program, err
+	}
+
+	return expanded, nil
+}
+
+// isMacroCall returns the macro called by call, if it calls one
+func isMacroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+// extendMacroEnv binds each macro parameter to its quoted argument
+func extendMacroEnv(macro *object.Macro, call *ast.CallExpression) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		extended.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+	return extended
+}
+
+// typeOf returns the type of obj, tolerating nil
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
@@ -0,0 +1,77 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// quote returns the unevaluated argument of a quote(...) call, after
+// replacing every unquote(...) call within it by its evaluated result
+func (e *Evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError(object.MacroError, "wrong number of arguments to `quote`: got=%d, want=1",
+			len(call.Arguments))
+	}
+
+	var err object.Object
+	node := ast.Modify(call.Arguments[0], func(node ast.Node) ast.Node {
+		unquote, ok := node.(*ast.CallExpression)
+		if !ok || !isCallTo(unquote, "unquote") || err != nil {
+			return node
+		}
+		if len(unquote.Arguments) != 1 {
+			err = newError(object.MacroError, "wrong number of arguments to `unquote`: got=%d, want=1",
+				len(unquote.Arguments))
+			return node
+		}
+
+		evaluated := e.Eval(unquote.Arguments[0], env)
+		if isError(evaluated) {
+			err = evaluated
+			return node
+		}
+
+		spliced, ok := objectToASTNode(evaluated, unquote.Pos())
+		if !ok {
+			err = newError(object.MacroError, "cannot unquote %s", evaluated.Type())
+			return node
+		}
+		return spliced
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// isCallTo reports whether call invokes the identifier name
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// objectToASTNode converts an evaluated object back into a literal node
+// positioned at pos
+func objectToASTNode(obj object.Object, pos token.Position) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprint(obj.Value), Pos: pos}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, true
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+	case *object.Quote:
+		return obj.Node, true
+	default:
+		return nil, false
+	}
+}
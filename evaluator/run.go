@@ -0,0 +1,32 @@
+package evaluator
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+)
+
+// Run lexes, parses and evaluates input in a fresh environment, expanding
+// its macros first, and returns the value of the program. If the input
+// does not lex or parse, nothing is evaluated and the lexer errors, or
+// else the parser errors, are returned instead
+func Run(input string) (object.Object, []string) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errors := l.Errors(); len(errors) != 0 {
+		return nil, errors
+	}
+	if errors := p.Errors(); len(errors) != 0 {
+		return nil, errors
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded, err := ExpandMacros(program, macroEnv)
+	if err != nil {
+		return err, nil
+	}
+
+	return Eval(expanded, object.NewEnvironment()), nil
+}
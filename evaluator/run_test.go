@@ -0,0 +1,47 @@
+package evaluator_test
+
+import (
+	"monkey/evaluator"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	input := `
+let unless = macro(cond, cons) { quote(if (!(unquote(cond))) { unquote(cons) }) };
+let add = fn(a, b) { a + b };
+unless(false, add(2, 3));
+`
+	evaluated, errors := evaluator.Run(input)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected parser errors: %q", errors)
+	}
+	testIntegerObject(t, evaluated, 5)
+}
+
+func TestRunEvaluationError(t *testing.T) {
+	evaluated, errors := evaluator.Run("1 + true")
+	if len(errors) != 0 {
+		t.Fatalf("unexpected parser errors: %q", errors)
+	}
+	testErrorObject(t, evaluated, "type mismatch: INTEGER + BOOLEAN")
+}
+
+func TestRunParserErrors(t *testing.T) {
+	evaluated, errors := evaluator.Run("let = 5; let x 5;")
+	if evaluated != nil {
+		t.Errorf("evaluated a program that does not parse. got=%s", evaluated.Inspect())
+	}
+
+	expected := []string{
+		"expected next token to be IDENT, got = instead",
+		"expected next token to be =, got INT instead",
+	}
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%q", len(expected), errors)
+	}
+	for i, msg := range expected {
+		if errors[i] != msg {
+			t.Errorf("errors[%d] wrong. want=%q, got=%q", i, msg, errors[i])
+		}
+	}
+}
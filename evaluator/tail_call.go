@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// tailCall is returned in place of the result of a function calling itself
+// in tail position. applyFunction catches it and re-enters the function
+// with the new arguments, so that tail recursion runs in constant stack
+type tailCall struct {
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// asTailCall returns a tailCall for the call of fn with args made by node,
+// if node is a self-call in tail position of the running function and the
+// arguments fit its parameters. Calls with the wrong number of arguments
+// go through applyFunction so that the error points at them
+func (e *Evaluator) asTailCall(node *ast.CallExpression, fn object.Object, args []object.Object) (*tailCall, bool) {
+	if e.current == nil || fn != e.current || !e.tailCallsOf(e.current.Body)[node] {
+		return nil, false
+	}
+	required := len(e.current.Parameters) - len(e.current.Defaults)
+	if len(args) < required || len(args) > len(e.current.Parameters) {
+		return nil, false
+	}
+	return &tailCall{args: args}, true
+}
+
+// tailCallsOf returns the calls in tail position of a function body,
+// analysing each body once
+func (e *Evaluator) tailCallsOf(body *ast.BlockStatement) map[*ast.CallExpression]bool {
+	if calls, ok := e.tailCalls[body]; ok {
+		return calls
+	}
+
+	calls := map[*ast.CallExpression]bool{}
+	markTailBlock(body, calls)
+	ast.Walk(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			// nested functions have tail calls of their own
+			return false
+		case *ast.ReturnStatement:
+			markTailExpression(node.ReturnValue, calls)
+		}
+		return true
+	})
+
+	if e.tailCalls == nil {
+		e.tailCalls = map[*ast.BlockStatement]map[*ast.CallExpression]bool{}
+	}
+	e.tailCalls[body] = calls
+	return calls
+}
+
+// markTailBlock marks the calls in tail position of the value of b
+func markTailBlock(b *ast.BlockStatement, calls map[*ast.CallExpression]bool) {
+	if b == nil || len(b.Statements) == 0 {
+		return
+	}
+
+	switch last := b.Statements[len(b.Statements)-1].(type) {
+	case *ast.ExpressionStatement:
+		markTailExpression(last.Expression, calls)
+	case *ast.BlockStatement:
+		markTailBlock(last, calls)
+	}
+}
+
+// markTailExpression marks the calls whose result is the value of exp
+func markTailExpression(exp ast.Expression, calls map[*ast.CallExpression]bool) {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		calls[exp] = true
+	case *ast.IfExpression:
+		markTailBlock(exp.Consequence, calls)
+		markTailBlock(exp.Alternative, calls)
+	case *ast.TernaryExpression:
+		markTailExpression(exp.Consequence, calls)
+		markTailExpression(exp.Alternative, calls)
+	case *ast.SwitchExpression:
+		for _, c := range exp.Cases {
+			markTailBlock(c.Body, calls)
+		}
+		markTailBlock(exp.Default, calls)
+	}
+}
@@ -0,0 +1,8 @@
+package lexer
+
+// State exposes the lexer's read positions to the tests: the byte index of
+// the current character, the byte index of the next one, and the current
+// character itself, 0 at the end of the input
+func (l *Lexer) State() (pos, readPos int, ch rune) {
+	return l.position, l.readPosition, l.ch
+}
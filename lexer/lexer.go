@@ -2,65 +2,186 @@
 package lexer
 
 import (
+	"fmt"
 	"monkey/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Lexer translates source code into tokens
 type Lexer struct {
 	input        string
-	position     int  // current index of the input (current byte character)
-	readPosition int  // next index of the input (next byte character)
-	ch           byte // current byte character
+	position     int  // byte index of the current character in the input
+	readPosition int  // byte index of the next character in the input
+	ch           rune // current character
+	line         int  // line of the current character
+	column       int  // column of the current character
+
+	preserveWhitespace bool // emit WHITESPACE and NEWLINE tokens
+	maxInputSize       int  // longest input in bytes accepted, 0 for no limit
+
+	errors []string // lexical problems found so far
+}
+
+// Option configures a Lexer
+type Option func(*Lexer)
+
+// PreserveWhitespace makes the lexer emit runs of blanks as WHITESPACE
+// tokens and line breaks as NEWLINE tokens instead of skipping them
+func PreserveWhitespace() Option {
+	return func(l *Lexer) {
+		l.preserveWhitespace = true
+	}
+}
+
+// MaxInputSize makes the lexer refuse input longer than size bytes,
+// reporting an error and returning only EOF instead of tokenizing it.
+// By default there is no limit
+func MaxInputSize(size int) Option {
+	return func(l *Lexer) {
+		l.maxInputSize = size
+	}
 }
 
 // New initialises a Lexer
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{input: input, line: 1}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.maxInputSize > 0 && len(input) > l.maxInputSize {
+		l.addError(token.Position{Line: 1, Column: 1},
+			"input of %d bytes exceeds the limit of %d", len(input), l.maxInputSize)
+		l.input = ""
+	}
 	l.readChar()
 	return l
 }
 
+// Errors returns the lexical problems found in the input read so far, such
+// as illegal characters and unterminated strings, each prefixed by its
+// position. The offending tokens are still returned by NextToken
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
+// addError records a lexical problem found at pos
+func (l *Lexer) addError(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, pos.String()+": "+fmt.Sprintf(format, a...))
+}
+
 // readChar reads each character and updates the Lexer's fields.
-// It does so by advancing the current position one at a time at each call
-// until the end of the input.
+// It does so by advancing the current position one UTF-8 encoded rune
+// at a time at each call until the end of the input.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
 }
 
-// peekChar returns the next byte character to the current one
-func (l *Lexer) peekChar() byte {
+// peekChar returns the character next to the current one
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
 }
 
 // skipWhiteSpace calls readChar() on the lexer if the current character
 // is a whitespace of some kind
 func (l *Lexer) skipWhiteSpace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.atLineContinuation():
+			l.skipLineContinuation()
+		default:
+			return
+		}
+	}
+}
+
+// atLineContinuation reports whether the current character is a backslash
+// ending its line, which joins the line to the next one
+func (l *Lexer) atLineContinuation() bool {
+	if l.ch != '\\' {
+		return false
+	}
+	rest := l.input[l.readPosition:]
+	return strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n")
+}
+
+// skipLineContinuation reads past a backslash and the line break after it
+func (l *Lexer) skipLineContinuation() {
+	l.readChar()
+	if l.ch == '\r' {
 		l.readChar()
 	}
+	l.readChar()
+}
+
+// readWhitespace returns a NEWLINE token for a line break, or a WHITESPACE
+// token holding the whole run of blanks, including line continuations,
+// starting at the current character.
+// It reports false if the current character is not whitespace
+func (l *Lexer) readWhitespace() (token.Token, bool) {
+	pos := token.Position{Line: l.line, Column: l.column}
+	position := l.position
+
+	switch {
+	case l.ch == '\n':
+		l.readChar()
+		return token.Token{Type: token.NEWLINE, Literal: "\n", Pos: pos}, true
+	case l.ch == '\r' && l.peekChar() == '\n':
+		l.readChar()
+		l.readChar()
+		return token.Token{Type: token.NEWLINE, Literal: "\r\n", Pos: pos}, true
+	}
+
+	for {
+		if l.ch == ' ' || l.ch == '\t' || (l.ch == '\r' && l.peekChar() != '\n') {
+			l.readChar()
+		} else if l.atLineContinuation() {
+			l.skipLineContinuation()
+		} else {
+			break
+		}
+	}
+	if l.position == position {
+		return token.Token{}, false
+	}
+	return token.Token{Type: token.WHITESPACE, Literal: l.input[position:l.position], Pos: pos}, true
 }
 
 // NextToken returns a new Token depending on the current character
 func (l *Lexer) NextToken() token.Token {
+	if l.preserveWhitespace {
+		if tok, ok := l.readWhitespace(); ok {
+			return tok
+		}
+	}
 	l.skipWhiteSpace()
 	var tok token.Token
+	pos := token.Position{Line: l.line, Column: l.column}
 	switch l.ch {
 	case '=':
 		// Check if this is an EQ operator "=="
 		if l.peekChar() == '=' {
-			ch := l.ch                           // save the current character
-			l.readChar()                         // move forward, updating l.ch
-			literal := string(ch) + string(l.ch) // compose the literal with previous and current ch
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = l.readTwoCharToken(token.EQ)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -73,7 +194,12 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		// Check if this is an INCR operator "++"
+		if l.peekChar() == '+' {
+			tok = l.readTwoCharToken(token.INCR)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -85,28 +211,74 @@ func (l *Lexer) NextToken() token.Token {
 	case '!':
 		// Check if this is an NOT_EQ operator "!="
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
+			tok = l.readTwoCharToken(token.NOT_EQ)
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		// Check if this is a DECR operator "--"
+		if l.peekChar() == '-' {
+			tok = l.readTwoCharToken(token.DECR)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
 		tok = newToken(token.GT, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		literal, ok := l.readString()
+		tok = token.Token{Type: token.STRING, Literal: literal}
+		if !ok {
+			l.addError(pos, "unterminated string")
+		}
+	case '\'':
+		literal, ok := l.readCharLiteral()
+		tok = token.Token{Type: token.CHAR, Literal: literal}
+		if !ok {
+			tok.Type = token.ILLEGAL
+			l.addError(pos, "unterminated char literal")
+		}
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '|':
+		// Check if this is a PIPE operator "|>" or an OR operator "||"
+		switch l.peekChar() {
+		case '>':
+			tok = l.readTwoCharToken(token.PIPE)
+		case '|':
+			tok = l.readTwoCharToken(token.OR)
+		default:
+			tok = newToken(token.ILLEGAL, l.ch)
+			l.addError(pos, "illegal character %q", l.ch)
+		}
+	case '&':
+		// Check if this is an AND operator "&&"
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+			l.addError(pos, "illegal character %q", l.ch)
+		}
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], token.ELLIPSIS) {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: token.ELLIPSIS}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+			l.addError(pos, "illegal character %q", l.ch)
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -116,6 +288,7 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		}
 		// In this case, the character is a number.
@@ -124,36 +297,48 @@ func (l *Lexer) NextToken() token.Token {
 		if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.INT
+			tok.Pos = pos
 			return tok
 		}
 		// If we end up here, we don't know how to handle this character
 		// and we mark it as illegal
 		tok = newToken(token.ILLEGAL, l.ch)
+		l.addError(pos, "illegal character %q", l.ch)
 	}
 	// advance
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
+// readTwoCharToken consumes the first character of a two character
+// operator and returns its token. The operators are spelled like their
+// token type, so the literal needs no allocation
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(tokenType)}
+}
+
 // newToken initialises a Token
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// isLetter identifies whether a byte represents a letter or not
+// isLetter identifies whether a character represents a letter or not,
+// in any script, so that identifiers such as café are valid.
 // An underscore is considered a valid letter,
 // so we can enable identifiers such as some_number
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-// isDigit identifies whether a character represents a number or not
-func isDigit(ch byte) bool {
-	return '0' <= ch && ch <= '9'
+// isDigit identifies whether a character represents a decimal digit or not
+func isDigit(ch rune) bool {
+	return unicode.IsDigit(ch)
 }
 
 // readIdentifier continues reading the string from the current position
-// until the byte is not a letter anymore, and returns the resulting string
+// until the character is not a letter anymore, and returns the resulting string
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 	for isLetter(l.ch) {
@@ -163,7 +348,7 @@ func (l *Lexer) readIdentifier() string {
 }
 
 // readNumber continues reading the string from the current position
-// until the byte is not a digit anymore, and returns the resulting string
+// until the character is not a digit anymore, and returns the resulting string
 func (l *Lexer) readNumber() string {
 	position := l.position
 	for isDigit(l.ch) {
@@ -172,8 +357,31 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
-// readString returns the string from initial position until " or the end of the input
-func (l *Lexer) readString() string {
+// readCharLiteral returns the text between single quotes, leaving escape
+// sequences as they are. It reports false if the closing quote is missing
+func (l *Lexer) readCharLiteral() (string, bool) {
+	position := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '\\' {
+			l.readChar()
+			if l.ch == 0 || l.ch == '\n' {
+				return l.input[position:l.position], false
+			}
+			continue
+		}
+		if l.ch == '\'' {
+			return l.input[position:l.position], true
+		}
+		if l.ch == 0 || l.ch == '\n' {
+			return l.input[position:l.position], false
+		}
+	}
+}
+
+// readString returns the string from initial position until " or the end of the input,
+// reporting false if the input ends first
+func (l *Lexer) readString() (string, bool) {
 	position := l.position + 1
 	for {
 		l.readChar()
@@ -181,5 +389,5 @@ func (l *Lexer) readString() string {
 			break
 		}
 	}
-	return l.input[position:l.position]
+	return l.input[position:l.position], l.ch == '"'
 }
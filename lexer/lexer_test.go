@@ -3,6 +3,7 @@ package lexer_test
 import (
 	"monkey/lexer"
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -33,6 +34,18 @@ func TestNextToken(t *testing.T) {
 		"foo bar"
 		[1, 2];
 		{"foo": "bar"}
+		while (x) { x }
+		break; continue;
+		i++; i--;
+		10 % 3;
+		macro(x, y) { x + y; };
+		~0;
+		a ? b : c;
+		let [x, ...y] = z;
+		x |> f;
+		do { x } while (y);
+		for (;;) {}
+		const max = 1;
 	`
 
 	tests := []struct {
@@ -131,9 +144,414 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.INCR, "++"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.DECR, "--"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.PERCENT, "%"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.TILDE, "~"},
+		{token.INT, "0"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "y"},
+		{token.RBRACKET, "]"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "z"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.SEMICOLON, ";"},
+		{token.DO, "do"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.SEMICOLON, ";"},
+		{token.SEMICOLON, ";"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.CONST, "const"},
+		{token.IDENT, "max"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x == \"ab\";"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLine int
+		expectedCol  int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.EQ, 2, 5},
+		{token.STRING, 2, 8},
+		{token.SEMICOLON, 2, 12},
+		{token.EOF, 2, 13},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%s",
+				i, tt.expectedLine, tt.expectedCol, tok.Pos)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let café = 5; 名前 + über_alles;`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedCol     int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "café", 5},
+		{token.ASSIGN, "=", 10},
+		{token.INT, "5", 12},
+		{token.SEMICOLON, ";", 13},
+		{token.IDENT, "名前", 15},
+		{token.PLUS, "+", 18},
+		{token.IDENT, "über_alles", 20},
+		{token.SEMICOLON, ";", 30},
+		{token.EOF, "", 31},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedCol, tok.Pos.Column)
+		}
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	input := `'a' a 'b'; '\n' '\'' 'é' 'ab' '' 'x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.CHAR, "a"},
+		{token.IDENT, "a"},
+		{token.CHAR, "b"},
+		{token.SEMICOLON, ";"},
+		{token.CHAR, `\n`},
+		{token.CHAR, `\'`},
+		{token.CHAR, "é"},
+		// the parser rejects literals that are not a single character
+		{token.CHAR, "ab"},
+		{token.CHAR, ""},
+		{token.ILLEGAL, "x"},
+		{token.EOF, ""},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedCharEscape(t *testing.T) {
+	tests := []struct {
+		input  string
+		tokens []token.Token
+	}{
+		{`'\`, []token.Token{{Type: token.ILLEGAL, Literal: `\`}, {Type: token.EOF}}},
+		{"'\\\nx", []token.Token{{Type: token.ILLEGAL, Literal: `\`}, {Type: token.IDENT, Literal: "x"}, {Type: token.EOF}}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		for i, want := range tt.tokens {
+			tok := l.NextToken()
+			if tok.Type != want.Type || tok.Literal != want.Literal {
+				t.Fatalf("tokens[%d] wrong for %q. want=%q %q, got=%q %q",
+					i, tt.input, want.Type, want.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestPreserveWhitespace(t *testing.T) {
+	input := "let x  = 5;\n\tx\r\n  +\t 1"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.WHITESPACE, " "},
+		{token.IDENT, "x"},
+		{token.WHITESPACE, "  "},
+		{token.ASSIGN, "="},
+		{token.WHITESPACE, " "},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.NEWLINE, "\n"},
+		{token.WHITESPACE, "\t"},
+		{token.IDENT, "x"},
+		{token.NEWLINE, "\r\n"},
+		{token.WHITESPACE, "  "},
+		{token.PLUS, "+"},
+		{token.WHITESPACE, "\t "},
+		{token.INT, "1"},
 		{token.EOF, ""},
 	}
 
+	l := lexer.New(input, lexer.PreserveWhitespace())
+	var rebuilt strings.Builder
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		rebuilt.WriteString(tok.Literal)
+	}
+
+	if rebuilt.String() != input {
+		t.Errorf("token literals do not add up to the input. got=%q", rebuilt.String())
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	input := "1 + \\\n 2"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     token.Position
+	}{
+		{token.INT, "1", token.Position{Line: 1, Column: 1}},
+		{token.PLUS, "+", token.Position{Line: 1, Column: 3}},
+		{token.INT, "2", token.Position{Line: 2, Column: 2}},
+		{token.EOF, "", token.Position{Line: 2, Column: 3}},
+	}
+
+	l := lexer.New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%v, got=%v", i, tt.expectedPos, tok.Pos)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+
+	preserved := "1 \\\r\n+ 2"
+	l = lexer.New(preserved, lexer.PreserveWhitespace())
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.WHITESPACE || tok.Literal != " \\\r\n" {
+		t.Errorf("continuation not kept as whitespace. got=%q (%q)", tok.Type, tok.Literal)
+	}
+}
+
+func TestState(t *testing.T) {
+	input := "let é = 10;"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedPos     int
+		expectedReadPos int
+		expectedCh      rune
+	}{
+		// after each token the lexer rests on the character that follows it
+		{token.LET, 3, 4, ' '},
+		{token.IDENT, 6, 7, ' '}, // é is two bytes long
+		{token.ASSIGN, 8, 9, ' '},
+		{token.INT, 11, 12, ';'},
+		{token.SEMICOLON, 12, 13, 0},
+		{token.EOF, 13, 14, 0},
+	}
+
+	l := lexer.New(input)
+	if pos, readPos, ch := l.State(); pos != 0 || readPos != 1 || ch != 'l' {
+		t.Fatalf("wrong initial state. got=(%d, %d, %q)", pos, readPos, ch)
+	}
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		pos, readPos, ch := l.State()
+		if pos != tt.expectedPos || readPos != tt.expectedReadPos || ch != tt.expectedCh {
+			t.Fatalf("tests[%d] - state wrong. expected=(%d, %d, %q), got=(%d, %d, %q)",
+				i, tt.expectedPos, tt.expectedReadPos, tt.expectedCh, pos, readPos, ch)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 5;`, nil},
+		{"let x = 5 $ 3;", []string{"1:11: illegal character '$'"}},
+		{"let s = \"abc", []string{"1:9: unterminated string"}},
+		{"a | b", []string{"1:3: illegal character '|'"}},
+		{"a & b", []string{"1:3: illegal character '&'"}},
+		{"a.b ..", []string{"1:2: illegal character '.'", "1:5: illegal character '.'", "1:6: illegal character '.'"}},
+		{"'a' 'b\n@", []string{"1:5: unterminated char literal", "2:1: illegal character '@'"}},
+		{"'\\", []string{"1:1: unterminated char literal"}},
+		{"'\\\n@", []string{"1:1: unterminated char literal", "2:1: illegal character '@'"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong errors for %q. want=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("errors[%d] wrong for %q. want=%q, got=%q", i, tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+func TestMaxInputSize(t *testing.T) {
+	l := lexer.New("let x = 5;", lexer.MaxInputSize(10))
+	if tok := l.NextToken(); tok.Type != token.LET || len(l.Errors()) != 0 {
+		t.Errorf("input within the limit refused. got=%q, errors=%q", tok.Type, l.Errors())
+	}
+
+	l = lexer.New("let x = 50;", lexer.MaxInputSize(10))
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Errorf("input over the limit tokenized. got=%q", tok.Type)
+	}
+	want := "1:1: input of 11 bytes exceeds the limit of 10"
+	if errors := l.Errors(); len(errors) != 1 || errors[0] != want {
+		t.Errorf("wrong errors. want=%q, got=%q", want, errors)
+	}
+}
+
+func TestTwoCharOperators(t *testing.T) {
+	input := "a == b != c++ d-- = ! + - && ||"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedCol     int
+	}{
+		{token.IDENT, "a", 1},
+		{token.EQ, "==", 3},
+		{token.IDENT, "b", 6},
+		{token.NOT_EQ, "!=", 8},
+		{token.IDENT, "c", 11},
+		{token.INCR, "++", 12},
+		{token.IDENT, "d", 15},
+		{token.DECR, "--", 16},
+		{token.ASSIGN, "=", 19},
+		{token.BANG, "!", 21},
+		{token.PLUS, "+", 23},
+		{token.MINUS, "-", 25},
+		{token.AND, "&&", 27},
+		{token.OR, "||", 30},
+		{token.EOF, "", 32},
+	}
+
 	l := lexer.New(input)
 	for i, tt := range tests {
 		tok := l.NextToken()
@@ -143,5 +561,31 @@ func TestNextToken(t *testing.T) {
 		if tok.Literal != tt.expectedLiteral {
 			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
 		}
+		if tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedCol, tok.Pos.Column)
+		}
+	}
+}
+
+func TestTwoCharOperatorsDoNotAllocate(t *testing.T) {
+	const runs = 100
+	l := lexer.New(strings.Repeat("== != ++ -- && || ", runs))
+
+	allocs := testing.AllocsPerRun(runs, func() {
+		l.NextToken()
+	})
+	if allocs != 0 {
+		t.Errorf("NextToken allocated %v times per two character operator, expected none", allocs)
+	}
+}
+
+func BenchmarkNextTokenTwoCharOperators(b *testing.B) {
+	input := strings.Repeat("a == b != c; i++; j--;\n", 100)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		l := lexer.New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
 	}
 }
@@ -1,18 +1,30 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"monkey/repl"
 	"os"
 	"os/user"
+	"path/filepath"
 )
 
 func main() {
+	engine := flag.String("engine", string(repl.EngineEval), "engine running the code: eval or vm")
+	flag.Parse()
+	if *engine != string(repl.EngineEval) && *engine != string(repl.EngineVM) {
+		fmt.Fprintf(os.Stderr, "unknown engine %q, expected eval or vm\n", *engine)
+		os.Exit(2)
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	history := filepath.Join(user.HomeDir, ".monkey_history")
+	repl.Start(os.Stdin, os.Stdout,
+		repl.HistoryFile(history, repl.DefaultHistorySize),
+		repl.WithEngine(repl.Engine(*engine)))
 }
@@ -0,0 +1,39 @@
+package object
+
+import "math"
+
+// CheckedArithmetic applies the integer operator +, -, *, / or % to a and
+// b, reporting false if the result does not fit in an int64. b must not be
+// 0 for / and %
+func CheckedArithmetic(operator string, a, b int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := a + b
+		// overflow happens when both operands have the same sign
+		// and the result's sign differs from it
+		return result, (a >= 0) != (b >= 0) || (result >= 0) == (a >= 0)
+	case "-":
+		result := a - b
+		// overflow happens when the operands have different signs
+		// and the result's sign differs from the left operand's
+		return result, (a >= 0) == (b >= 0) || (result >= 0) == (a >= 0)
+	case "*":
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		result := a * b
+		if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return result, false
+		}
+		return result, result/b == a
+	case "/":
+		if a == math.MinInt64 && b == -1 {
+			return a, false
+		}
+		return a / b, true
+	case "%":
+		// the remainder can't overflow, math.MinInt64 % -1 is 0
+		return a % b, true
+	}
+	return 0, false
+}
@@ -0,0 +1,26 @@
+package object
+
+// DeepCopy returns a copy of obj that shares no mutable state with it.
+// Arrays and hashes are copied recursively, while scalars, which cannot
+// be changed in place, are returned as they are. Functions, builtins and
+// macros are shared too, along with the environments they close over.
+// Monkey values cannot refer to themselves, so there are no cycles to
+// detect.
+func DeepCopy(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := make([]Object, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = DeepCopy(el)
+		}
+		return &Array{Elements: elements}
+	case *Hash:
+		pairs := make(map[HashKey]HashPair, len(obj.Pairs))
+		for key, pair := range obj.Pairs {
+			pairs[key] = HashPair{Key: pair.Key, Value: DeepCopy(pair.Value)}
+		}
+		return &Hash{Pairs: pairs}
+	default:
+		return obj
+	}
+}
@@ -0,0 +1,53 @@
+package object
+
+// Equals compares left and right structurally, as the == operator does.
+// Values, arrays and hashes are equal when their contents are, functions
+// only when they are the same object. Objects of different types are never
+// equal
+func Equals(left, right Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *Null:
+		return true
+	case *Integer:
+		return left.Value == right.(*Integer).Value
+	case *Boolean:
+		return left.Value == right.(*Boolean).Value
+	case *String:
+		return left.Value == right.(*String).Value
+	case *Array:
+		right := right.(*Array)
+		if len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for i, el := range left.Elements {
+			if !Equals(el, right.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		right := right.(*Hash)
+		if len(left.Pairs) != len(right.Pairs) {
+			return false
+		}
+		for key, pair := range left.Pairs {
+			other, ok := right.Pairs[key]
+			if !ok || !Equals(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
+
+// IsHashable reports whether obj can be used as a hash key
+func IsHashable(obj Object) bool {
+	_, ok := obj.(Hashable)
+	return ok
+}
@@ -0,0 +1,55 @@
+package object
+
+import "strings"
+
+// InspectLimits bounds how much of a large or deeply nested array or hash
+// is rendered when inspecting it. A limit of 0 or less disables it
+type InspectLimits struct {
+	MaxDepth    int // nesting levels rendered, deeper ones show as [...] or {...}
+	MaxElements int // elements rendered per array or hash, the rest show as ...
+}
+
+// DefaultInspectLimits are the limits applied by the Inspect method of
+// arrays and hashes
+var DefaultInspectLimits = InspectLimits{MaxDepth: 32, MaxElements: 1000}
+
+// InspectLimited returns the printable form of obj, like its Inspect
+// method, but within limits
+func InspectLimited(obj Object, limits InspectLimits) string {
+	return limits.inspect(obj, 0)
+}
+
+// inspect renders obj found depth levels deep in the object being
+// inspected
+func (l InspectLimits) inspect(obj Object, depth int) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if l.MaxDepth > 0 && depth >= l.MaxDepth {
+			return "[...]"
+		}
+		elements := []string{}
+		for i, e := range obj.Elements {
+			if l.MaxElements > 0 && i >= l.MaxElements {
+				elements = append(elements, "...")
+				break
+			}
+			elements = append(elements, l.inspect(e, depth+1))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Hash:
+		if l.MaxDepth > 0 && depth >= l.MaxDepth {
+			return "{...}"
+		}
+		pairs := []string{}
+		for _, pair := range obj.SortedPairs() {
+			if l.MaxElements > 0 && len(pairs) >= l.MaxElements {
+				pairs = append(pairs, "...")
+				break
+			}
+			pairs = append(pairs, l.inspect(pair.Key, depth+1)+": "+l.inspect(pair.Value, depth+1))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
+	"monkey/code"
+	"monkey/token"
+	"sort"
 	"strings"
+	"sync"
 )
 
 type ObjectType string
@@ -19,8 +23,14 @@ const (
 	INTEGER_OBJ      = "INTEGER"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	STRING_OBJ       = "STRING"
 	HASH_OBJ         = "HASH"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 // Object represents any object in the monkey language
@@ -45,6 +55,7 @@ var _ Object = (*Integer)(nil)
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) String() string   { return fmt.Sprintf("Integer(%d)", i.Value) }
 func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
@@ -58,6 +69,7 @@ var _ Object = (*Boolean)(nil)
 
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) String() string   { return fmt.Sprintf("Boolean(%t)", b.Value) }
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 	if b.Value {
@@ -75,6 +87,7 @@ var _ Object = (*Null)(nil)
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
+func (n *Null) String() string   { return "Null" }
 
 // ReturnValue is an Object wrapping another Object with the return value
 type ReturnValue struct {
@@ -85,26 +98,117 @@ var _ Object = (*ReturnValue)(nil)
 
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+func (rv *ReturnValue) String() string   { return fmt.Sprintf("ReturnValue(%v)", rv.Value) }
+
+// Break signals that the innermost enclosing loop must stop
+type Break struct{}
+
+var _ Object = (*Break)(nil)
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+func (b *Break) String() string   { return "Break" }
+
+// Continue signals that the innermost enclosing loop must skip
+// to its next iteration
+type Continue struct{}
+
+var _ Object = (*Continue)(nil)
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+func (c *Continue) String() string   { return "Continue" }
+
+// ErrorKind classifies errors so callers can tell them apart
+// without matching on their message
+type ErrorKind int
+
+const (
+	GenericError      ErrorKind = iota
+	TypeMismatch                // operands or values of the wrong type
+	UnknownOperator             // operator not defined for its operands
+	UnknownIdentifier           // name not bound in any scope
+	Redefinition                // name already bound in the same scope
+	ConstAssignment             // reassignment of a const binding
+	NotAFunction                // call of a value that is not callable
+	ArgumentError               // bad arguments passed to a builtin
+	IndexError                  // bad index or slice operation
+	ArithmeticError             // overflow or division by zero
+	ControlFlowError            // break or continue outside a loop
+	LimitExceeded               // evaluation ran too deep or too long
+	Cancelled                   // evaluation context was done
+	MacroError                  // failed quote, unquote or macro expansion
+)
+
+var errorKindNames = map[ErrorKind]string{
+	GenericError:      "GenericError",
+	TypeMismatch:      "TypeMismatch",
+	UnknownOperator:   "UnknownOperator",
+	UnknownIdentifier: "UnknownIdentifier",
+	Redefinition:      "Redefinition",
+	ConstAssignment:   "ConstAssignment",
+	NotAFunction:      "NotAFunction",
+	ArgumentError:     "ArgumentError",
+	IndexError:        "IndexError",
+	ArithmeticError:   "ArithmeticError",
+	ControlFlowError:  "ControlFlowError",
+	LimitExceeded:     "LimitExceeded",
+	Cancelled:         "Cancelled",
+	MacroError:        "MacroError",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
 
 // Error is an object wrapping an error message
 type Error struct {
-	Message string
+	Kind    ErrorKind
+	Message string         // human readable description
+	Pos     token.Position // where the error was raised, if known
 }
 
 var _ Object = (*Error)(nil)
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.Line == 0 {
+		return "ERROR: " + e.Message
+	}
+	return "ERROR: " + e.Pos.String() + ": " + e.Message
+}
+func (e *Error) String() string {
+	if e.Pos.Line == 0 {
+		return fmt.Sprintf("Error(%s: %s)", e.Kind, e.Message)
+	}
+	return fmt.Sprintf("Error(%s: %s: %s)", e.Kind, e.Pos, e.Message)
+}
 
 // Environment helps keeping tracj of values associated to names, for example
-// for let statements
+// for let statements.
+// An Environment is safe for concurrent use: its bindings are guarded by a
+// read/write lock, so scripts sharing it from several goroutines may read in
+// parallel while writes are serialised. Every lookup pays for taking the
+// lock, once per scope it walks through, which is a small cost next to
+// evaluation but adds up in tight loops over deeply nested scopes.
+// The objects it holds are not themselves protected.
 type Environment struct {
-	store map[string]Object
+	mu    sync.RWMutex
+	store map[string]binding
 	outer *Environment
 }
 
+// binding is a value bound to a name, and whether it may be reassigned
+type binding struct {
+	value   Object
+	mutable bool
+}
+
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
+	s := make(map[string]binding)
 	return &Environment{store: s, outer: nil}
 }
 
@@ -115,21 +219,90 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
+	obj, ok := e.GetLocal(name)
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 
+// GetLocal returns the value bound to name in this environment only,
+// ignoring outer environments
+func (e *Environment) GetLocal(name string) (Object, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	b, ok := e.store[name]
+	return b.value, ok
+}
+
 func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.store[name] = binding{value: val, mutable: true}
 	return val
 }
 
+// SetImmutable binds name to val like Set, but marks the binding as one
+// that must not be reassigned
+func (e *Environment) SetImmutable(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.store[name] = binding{value: val}
+	return val
+}
+
+// IsMutable reports whether the binding of name in this environment may be
+// reassigned, and whether name is bound here at all
+func (e *Environment) IsMutable(name string) (bool, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	b, ok := e.store[name]
+	return b.mutable, ok
+}
+
+// Scope returns the environment, either this one or one of its outer
+// environments, in which name is bound
+func (e *Environment) Scope(name string) (*Environment, bool) {
+	if _, ok := e.GetLocal(name); ok {
+		return e, true
+	}
+	if e.outer != nil {
+		return e.outer.Scope(name)
+	}
+	return nil, false
+}
+
+// Clone returns a copy of the environment whose bindings can be changed
+// without affecting e. Only the local bindings are copied: the values they
+// hold are shared, as is the outer environment, so assigning to a name bound
+// in an outer scope through the clone still changes it for e.
+func (e *Environment) Clone() *Environment {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	store := make(map[string]binding, len(e.store))
+	for name, b := range e.store {
+		store[name] = b
+	}
+	return &Environment{store: store, outer: e.outer}
+}
+
+// Keys returns the names bound in this environment, sorted alphabetically.
+// Names bound in outer environments are not included.
+func (e *Environment) Keys() []string {
+	e.mu.RLock()
+	keys := make([]string, 0, len(e.store))
+	for name := range e.store {
+		keys = append(keys, name)
+	}
+	e.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
 // Function keeps track of function objects
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -140,20 +313,37 @@ func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(strings.Join(ast.ParameterList(f.Parameters, f.Defaults), ", "))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
+func (f *Function) String() string {
+	return "Function(fn(" + strings.Join(ast.ParameterList(f.Parameters, f.Defaults), ", ") + "))"
+}
+
+// CompiledFunction is a function compiled to bytecode, to be run by the
+// virtual machine
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // number of local bindings, parameters included
+	NumParameters int
+}
+
+var _ Object = (*CompiledFunction)(nil)
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+func (cf *CompiledFunction) String() string {
+	return fmt.Sprintf("CompiledFunction(%d parameters, %d locals, %d bytes)",
+		cf.NumParameters, cf.NumLocals, len(cf.Instructions))
+}
 
 // String is an object representing a string in the monkey language
 type String struct {
@@ -164,6 +354,7 @@ var _ Object = (*String)(nil)
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
+func (s *String) String() string   { return fmt.Sprintf("String(%q)", s.Value) }
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -172,15 +363,22 @@ func (s *String) HashKey() HashKey {
 
 type BuiltinFunction func(args ...Object) Object
 
+// VARIADIC is the MaxArgs of builtins accepting any number of arguments
+const VARIADIC = -1
+
 // Builtin represents a builtin function
 type Builtin struct {
-	Fn BuiltinFunction
+	Name    string
+	MinArgs int             // minimum number of arguments
+	MaxArgs int             // maximum number of arguments, or VARIADIC
+	Fn      BuiltinFunction // called with a number of arguments in that range
 }
 
 var _ Object = (*Builtin)(nil)
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
+func (b *Builtin) String() string   { return "Builtin(" + b.Name + ")" }
 
 // Array represents an array in the monkey language
 type Array struct {
@@ -191,18 +389,9 @@ var _ Object = (*Array)(nil)
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
 func (ao *Array) Inspect() string {
-	var out bytes.Buffer
-
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
-	}
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-
-	return out.String()
+	return InspectLimited(ao, DefaultInspectLimits)
 }
+func (ao *Array) String() string { return "Array(" + Source(ao) + ")" }
 
 type HashPair struct {
 	Key   Object
@@ -215,20 +404,61 @@ type Hash struct {
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 func (h *Hash) Inspect() string {
-	var out bytes.Buffer
+	return InspectLimited(h, DefaultInspectLimits)
+}
+func (h *Hash) String() string { return "Hash(" + Source(h) + ")" }
 
-	pairs := []string{}
+// SortedPairs returns the pairs of the hash ordered by the source form of
+// their keys, giving a stable order where iterating over Pairs does not
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
 	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+		pairs = append(pairs, pair)
 	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return Source(pairs[i].Key) < Source(pairs[j].Key)
+	})
+	return pairs
+}
+
+// Quote wraps an unevaluated AST node
+type Quote struct {
+	Node ast.Node
+}
+
+var _ Object = (*Quote)(nil)
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+func (q *Quote) String() string { return "Quote(" + q.Node.String() + ")" }
+
+// Macro keeps track of macros defined with the macro keyword
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+var _ Object = (*Macro)(nil)
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(ast.ParameterList(m.Parameters, nil), ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
 
 	return out.String()
 }
+func (m *Macro) String() string {
+	return "Macro(macro(" + strings.Join(ast.ParameterList(m.Parameters, nil), ", ") + "))"
+}
 
 type Hashable interface {
 	HashKey() HashKey
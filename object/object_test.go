@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+	"sync"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +64,401 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestEnvironmentKeys(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("outer", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("b", &Integer{Value: 2})
+	env.Set("a", &Integer{Value: 3})
+
+	keys := env.Keys()
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("env.Keys returned %v, expected [a b]", keys)
+	}
+}
+
+func TestEnvironmentClone(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("o", &Integer{Value: 0})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("a", &Integer{Value: 1})
+
+	clone := env.Clone()
+	clone.Set("a", &Integer{Value: 2})
+	clone.Set("b", &Integer{Value: 3})
+
+	if a, _ := env.Get("a"); a.(*Integer).Value != 1 {
+		t.Errorf("original a changed. got=%d", a.(*Integer).Value)
+	}
+	if _, ok := env.Get("b"); ok {
+		t.Errorf("b bound in the original environment")
+	}
+	if a, _ := clone.Get("a"); a.(*Integer).Value != 2 {
+		t.Errorf("clone a wrong. got=%d", a.(*Integer).Value)
+	}
+	if _, ok := clone.Get("o"); !ok {
+		t.Errorf("clone does not see the outer environment")
+	}
+}
+
+func TestEnvironmentGetLocal(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("y", &Integer{Value: 2})
+
+	if _, ok := env.Get("x"); !ok {
+		t.Errorf("Get did not find x in the outer environment")
+	}
+	if _, ok := env.GetLocal("x"); ok {
+		t.Errorf("GetLocal found x, which is only bound in the outer environment")
+	}
+	if _, ok := env.GetLocal("y"); !ok {
+		t.Errorf("GetLocal did not find y")
+	}
+}
+
+func TestEnvironmentSetImmutable(t *testing.T) {
+	outer := NewEnvironment()
+	outer.SetImmutable("c", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("v", &Integer{Value: 2})
+
+	if c, ok := outer.Get("c"); !ok || c.(*Integer).Value != 1 {
+		t.Errorf("immutable binding not readable. got=%v, %t", c, ok)
+	}
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		mutable  bool
+		hasLocal bool
+	}{
+		{outer, "c", false, true},
+		{env, "v", true, true},
+		// only bindings local to the environment are reported
+		{env, "c", false, false},
+		{env, "missing", false, false},
+	}
+	for _, tt := range tests {
+		mutable, ok := tt.env.IsMutable(tt.name)
+		if mutable != tt.mutable || ok != tt.hasLocal {
+			t.Errorf("IsMutable(%q) wrong. want=(%t, %t), got=(%t, %t)",
+				tt.name, tt.mutable, tt.hasLocal, mutable, ok)
+		}
+	}
+
+	// the clone keeps the binding immutable
+	if mutable, ok := outer.Clone().IsMutable("c"); mutable || !ok {
+		t.Errorf("clone lost immutability. got=(%t, %t)", mutable, ok)
+	}
+
+	// binding the name again with Set makes it mutable
+	outer.Set("c", &Integer{Value: 3})
+	if mutable, _ := outer.IsMutable("c"); !mutable {
+		t.Errorf("binding set with Set is not mutable")
+	}
+}
+
+// run with -race to check the environment's locking
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("shared", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			name := fmt.Sprintf("key%d", w)
+			for i := 0; i < 100; i++ {
+				env.Set(name, &Integer{Value: int64(i)})
+				if _, ok := env.Get(name); !ok {
+					t.Errorf("%s not found after being set", name)
+				}
+				if _, ok := env.Get("shared"); !ok {
+					t.Errorf("shared not found in outer environment")
+				}
+				env.Keys()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		obj, ok := env.Get(fmt.Sprintf("key%d", w))
+		if !ok || obj.(*Integer).Value != 99 {
+			t.Errorf("key%d wrong. got=%v (%t)", w, obj, ok)
+		}
+	}
+}
+
+func TestBuiltinObject(t *testing.T) {
+	builtin := &Builtin{
+		Name:    "id",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn:      func(args ...Object) Object { return args[0] },
+	}
+
+	if builtin.Type() != BUILTIN_OBJ {
+		t.Errorf("builtin.Type() wrong. want=%q, got=%q", BUILTIN_OBJ, builtin.Type())
+	}
+	if builtin.Inspect() != "builtin function" {
+		t.Errorf("builtin.Inspect() wrong. got=%q", builtin.Inspect())
+	}
+
+	env := NewEnvironment()
+	env.Set("f", builtin)
+
+	obj, ok := env.Get("f")
+	if !ok {
+		t.Fatalf("builtin not bound in environment")
+	}
+	if obj != builtin {
+		t.Errorf("environment returned a different object. got=%T (%+v)", obj, obj)
+	}
+}
+
+func TestDeepCopyArray(t *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 2}}}
+	original := &Array{Elements: []Object{&Integer{Value: 1}, inner}}
+
+	copied, ok := DeepCopy(original).(*Array)
+	if !ok {
+		t.Fatalf("DeepCopy did not return an array. got=%T", copied)
+	}
+	if copied == original || copied.Elements[1] == inner {
+		t.Fatalf("DeepCopy shares arrays with its source")
+	}
+	if copied.Inspect() != original.Inspect() {
+		t.Errorf("copy differs from source. want=%s, got=%s", original.Inspect(), copied.Inspect())
+	}
+
+	copied.Elements[0] = &Integer{Value: 10}
+	copied.Elements[1].(*Array).Elements = append(copied.Elements[1].(*Array).Elements, &Integer{Value: 3})
+
+	if original.Inspect() != "[1, [2]]" {
+		t.Errorf("changing the copy changed the source. got=%s", original.Inspect())
+	}
+}
+
+func TestDeepCopyHash(t *testing.T) {
+	key := &String{Value: "a"}
+	list := &Array{Elements: []Object{}}
+	original := &Hash{Pairs: map[HashKey]HashPair{key.HashKey(): {Key: key, Value: list}}}
+
+	copied := DeepCopy(original).(*Hash)
+	copied.Pairs[key.HashKey()].Value.(*Array).Elements = []Object{&Boolean{Value: true}}
+
+	if len(list.Elements) != 0 {
+		t.Errorf("changing the copy changed the source. got=%s", original.Inspect())
+	}
+}
+
+func TestDeepCopySharesScalars(t *testing.T) {
+	objs := []Object{&Integer{Value: 1}, &String{Value: "a"}, &Boolean{Value: true}, &Null{}}
+	for _, obj := range objs {
+		if DeepCopy(obj) != obj {
+			t.Errorf("DeepCopy copied scalar %s", obj.Inspect())
+		}
+	}
+}
+
+func TestEquals(t *testing.T) {
+	fn := &Function{Body: &ast.BlockStatement{}}
+	tests := []struct {
+		left, right Object
+		expected    bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Boolean{Value: true}, &Boolean{Value: false}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "a"}, &String{Value: "b"}, false},
+		{&Null{}, &Null{}, true},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&Integer{Value: 0}, &Null{}, false},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}},
+			true,
+		},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}}},
+			&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}},
+			false,
+		},
+		{
+			&Array{Elements: []Object{&Integer{Value: 1}}},
+			&Array{Elements: []Object{&Integer{Value: 2}}},
+			false,
+		},
+		{fn, fn, true},
+		{fn, &Function{Body: &ast.BlockStatement{}}, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equals(tt.left, tt.right); got != tt.expected {
+			t.Errorf("tests[%d] - Equals(%s, %s) wrong. want=%t, got=%t",
+				i, tt.left.Inspect(), tt.right.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestIsHashable(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, true},
+		{&Boolean{Value: true}, true},
+		{&String{Value: "a"}, true},
+		{&Null{}, false},
+		{&Array{}, false},
+		{&Hash{}, false},
+		{&Function{Body: &ast.BlockStatement{}}, false},
+		{&Builtin{}, false},
+		{&Error{Message: "oops"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsHashable(tt.obj); got != tt.expected {
+			t.Errorf("IsHashable(%s) wrong. want=%t, got=%t", tt.obj.Type(), tt.expected, got)
+		}
+	}
+}
+
+func TestInspectDepthLimit(t *testing.T) {
+	var nested Object = &Integer{Value: 1}
+	for i := 0; i < 5; i++ {
+		nested = &Array{Elements: []Object{nested}}
+	}
+
+	limits := InspectLimits{MaxDepth: 3}
+	if got := InspectLimited(nested, limits); got != "[[[[...]]]]" {
+		t.Errorf("wrong truncation at depth 3. got=%q", got)
+	}
+	if got := InspectLimited(nested, InspectLimits{}); got != "[[[[[1]]]]]" {
+		t.Errorf("wrong rendering without limits. got=%q", got)
+	}
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	key := &String{Value: "a"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: &Hash{Pairs: map[HashKey]HashPair{}}}
+	if got := InspectLimited(&Array{Elements: []Object{hash}}, InspectLimits{MaxDepth: 2}); got != "[{a: {...}}]" {
+		t.Errorf("wrong truncation of a nested hash. got=%q", got)
+	}
+}
+
+func TestInspectElementLimit(t *testing.T) {
+	arr := &Array{}
+	for i := 0; i < 10; i++ {
+		arr.Elements = append(arr.Elements, &Integer{Value: int64(i)})
+	}
+
+	if got := InspectLimited(arr, InspectLimits{MaxElements: 3}); got != "[0, 1, 2, ...]" {
+		t.Errorf("wrong truncation at 3 elements. got=%q", got)
+	}
+	if got := InspectLimited(arr, InspectLimits{MaxElements: 10}); got != arr.Inspect() {
+		t.Errorf("array within the limit was truncated. got=%q", got)
+	}
+}
+
+func TestHashInspectOrder(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{
+		&String{Value: "b"}, &Integer{Value: 2}, &String{Value: "a"},
+		&Boolean{Value: true}, &Integer{Value: 10}, &String{Value: "1"},
+	} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: &Integer{Value: 0}}
+	}
+
+	want := "{1: 0, a: 0, b: 0, 10: 0, 2: 0, true: 0}"
+	for i := 0; i < 20; i++ {
+		if got := hash.Inspect(); got != want {
+			t.Fatalf("wrong rendering on call %d. want=%q, got=%q", i, want, got)
+		}
+	}
+
+	if got := InspectLimited(hash, InspectLimits{MaxElements: 2}); got != "{1: 0, a: 0, ...}" {
+		t.Errorf("wrong truncation at 2 pairs. got=%q", got)
+	}
+}
+
+func TestObjectString(t *testing.T) {
+	a := &String{Value: "a"}
+	one := &Integer{Value: 1}
+	hash := &Hash{Pairs: map[HashKey]HashPair{
+		a.HashKey():   {Key: a, Value: &Boolean{Value: true}},
+		one.HashKey(): {Key: one, Value: &Null{}},
+	}}
+	params := []*ast.Identifier{{Value: "x"}, {Value: "y"}}
+	defaults := map[string]ast.Expression{
+		"y": &ast.IntegerLiteral{Token: token.Token{Literal: "2"}, Value: 2},
+	}
+	body := &ast.BlockStatement{}
+
+	tests := []struct {
+		obj      fmt.Stringer
+		expected string
+	}{
+		{&Integer{Value: -5}, "Integer(-5)"},
+		{&Boolean{Value: false}, "Boolean(false)"},
+		{&Null{}, "Null"},
+		{&ReturnValue{Value: one}, "ReturnValue(Integer(1))"},
+		{&Break{}, "Break"},
+		{&Continue{}, "Continue"},
+		{&Error{Kind: TypeMismatch, Message: "bad"}, "Error(TypeMismatch: bad)"},
+		{&Error{Kind: ArgumentError, Message: "bad", Pos: token.Position{Line: 2, Column: 3}},
+			"Error(ArgumentError: 2:3: bad)"},
+		{&Function{Parameters: params, Defaults: defaults, Body: body}, "Function(fn(x, y = 2))"},
+		{&CompiledFunction{Instructions: make([]byte, 4), NumLocals: 3, NumParameters: 1},
+			"CompiledFunction(1 parameters, 3 locals, 4 bytes)"},
+		{&String{Value: "say \"hi\""}, `String("say \"hi\"")`},
+		{&Builtin{Name: "len"}, "Builtin(len)"},
+		{&Array{Elements: []Object{one, a}}, `Array([1, "a"])`},
+		{hash, `Hash({"a": true, 1: null})`},
+		{&Quote{Node: &ast.Identifier{Value: "x"}}, "Quote(x)"},
+		{&Macro{Parameters: params, Body: body}, "Macro(macro(x, y))"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.String(); got != tt.expected {
+			t.Errorf("wrong String for %T. want=%q, got=%q", tt.obj, tt.expected, got)
+		}
+		if got := fmt.Sprintf("%v", tt.obj); got != tt.expected {
+			t.Errorf("wrong %%v formatting for %T. want=%q, got=%q", tt.obj, tt.expected, got)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	a, b := &String{Value: "a"}, &String{Value: "b"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{
+		b.HashKey(): {Key: b, Value: &Array{Elements: []Object{&Integer{Value: 2}}}},
+		a.HashKey(): {Key: a, Value: &String{Value: "x"}},
+	}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&String{Value: "hi"}, `"hi"`},
+		{&Integer{Value: -3}, "-3"},
+		{&Boolean{Value: true}, "true"},
+		{&Array{Elements: []Object{a, b}}, `["a", "b"]`},
+		{&Array{Elements: []Object{&Array{Elements: []Object{a}}, &Integer{Value: 1}}}, `[["a"], 1]`},
+		{hash, `{"a": "x", "b": [2]}`},
+	}
+
+	for _, tt := range tests {
+		if got := Source(tt.obj); got != tt.expected {
+			t.Errorf("Source(%s) wrong. want=%q, got=%q", tt.obj.Inspect(), tt.expected, got)
+		}
+	}
+}
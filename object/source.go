@@ -0,0 +1,37 @@
+package object
+
+import (
+	"monkey/ast"
+	"sort"
+	"strings"
+)
+
+// Source returns obj written as monkey source that evaluates back to an
+// equal value. Unlike Inspect, strings are quoted wherever they appear, so
+// arrays and hashes of strings read back as such. Hash pairs are sorted by
+// key to keep the output stable. Values with no literal form, such as
+// builtins, are rendered as by Inspect
+func Source(obj Object) string {
+	switch obj := obj.(type) {
+	case *String:
+		return `"` + obj.Value + `"`
+	case *Array:
+		elements := make([]string, 0, len(obj.Elements))
+		for _, e := range obj.Elements {
+			elements = append(elements, Source(e))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Hash:
+		pairs := make([]string, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, Source(pair.Key)+": "+Source(pair.Value))
+		}
+		sort.Strings(pairs)
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *Function:
+		literal := &ast.FunctionLiteral{Parameters: obj.Parameters, Defaults: obj.Defaults, Body: obj.Body}
+		return ast.Format(literal)
+	default:
+		return obj.Inspect()
+	}
+}
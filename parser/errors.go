@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+)
+
+// ParserError describes a problem found while parsing,
+// along with the token that caused it
+type ParserError struct {
+	Message string
+	Token   token.Token // the offending token
+}
+
+// Error returns the error message, so that a ParserError satisfies
+// the error interface
+func (pe ParserError) Error() string {
+	return pe.Message
+}
+
+// Errors returns the messages of the parser's errors
+func (p *Parser) Errors() []string {
+	messages := make([]string, 0, len(p.errors))
+	for _, err := range p.errors {
+		messages = append(messages, err.Message)
+	}
+	return messages
+}
+
+// ErrorsDetailed returns the parser's errors, including the offending tokens
+func (p *Parser) ErrorsDetailed() []ParserError {
+	return p.errors
+}
+
+// addError appends a new error about tok to p.errors
+func (p *Parser) addError(tok token.Token, format string, a ...interface{}) {
+	if p.abandoned {
+		return
+	}
+	p.errors = append(p.errors, ParserError{
+		Message: fmt.Sprintf(format, a...),
+		Token:   tok,
+	})
+}
@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"testing"
+)
+
+func TestErrorsDetailed(t *testing.T) {
+	l := lexer.New("let = 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.ErrorsDetailed()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. got=%d (%v)", len(errors), errors)
+	}
+
+	err := errors[0]
+	if err.Token.Type != token.ASSIGN {
+		t.Errorf("err.Token.Type not %q. got=%q", token.ASSIGN, err.Token.Type)
+	}
+	if err.Message != "expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+	if p.Errors()[0] != err.Message {
+		t.Errorf("Errors() does not match ErrorsDetailed(). got=%q", p.Errors()[0])
+	}
+}
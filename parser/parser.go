@@ -1,7 +1,7 @@
 package parser
 
 import (
-	"fmt"
+	"context"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
@@ -9,20 +9,31 @@ import (
 )
 
 const (
-	// Define precedences, with first entry being 0 and then 1 to 7
+	// Define precedences, with first entry being 0 and then 1 to 14
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y
+	PIPE        // x |> f
+	TERNARY     // x ? y : z
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
+	POSTFIX     // X++ or X--
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 // mapping of tokens to precedence values
 var precedences = map[token.TokenType]int{
+	token.ASSIGN:   ASSIGN,
+	token.PIPE:     PIPE,
+	token.QUESTION: TERNARY,
+	token.OR:       OR,
+	token.AND:      AND,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -31,33 +42,77 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
+	token.INCR:     POSTFIX,
+	token.DECR:     POSTFIX,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
 }
 
 // Custom types for parsing functions
 type (
-	prefixParseFn func() ast.Expression
-	infixParseFn  func(ast.Expression) ast.Expression
+	prefixParseFn  func() ast.Expression
+	infixParseFn   func(ast.Expression) ast.Expression
+	postfixParseFn func(ast.Expression) ast.Expression
 )
 
 // Parser has the facilities to turn tokens into objects part of the AST
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []ParserError
 
 	curToken  token.Token
 	peekToken token.Token
+	lookahead []token.Token // tokens read past peekToken by peekAt
 
-	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns  map[token.TokenType]infixParseFn
+	prefixParseFns  map[token.TokenType]prefixParseFn
+	infixParseFns   map[token.TokenType]infixParseFn
+	postfixParseFns map[token.TokenType]postfixParseFn
+
+	autoSemicolons bool // a line break ends an expression statement
+	nesting        int  // depth of brackets around the current token
+
+	depth     int  // depth of expressions being parsed
+	maxDepth  int  // deepest expression nesting accepted
+	abandoned bool // the rest of the input was skipped after an error
+}
+
+// DefaultMaxDepth is how deeply expressions may nest unless MaxDepth says
+// otherwise. It is well beyond any hand written program, yet far from
+// exhausting the stack
+const DefaultMaxDepth = 1000
+
+// Option configures a Parser
+type Option func(*Parser)
+
+// MaxDepth limits how deeply expressions may nest, so that input such as
+// thousands of nested parentheses is reported as an error rather than
+// overflowing the stack
+func MaxDepth(depth int) Option {
+	return func(p *Parser) {
+		p.maxDepth = depth
+	}
+}
+
+// AutoSemicolons makes a line break end an expression, as if a semicolon
+// had been inserted before it, unless it appears inside parentheses,
+// brackets or a hash literal. Without it, expressions continue across lines
+// until a semicolon, so `f\n(1)` is a call of f
+func AutoSemicolons() Option {
+	return func(p *Parser) {
+		p.autoSemicolons = true
+	}
 }
 
 // New initialises and returns a new Parser
-func New(l *lexer.Lexer) *Parser {
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:        l,
+		errors:   []ParserError{},
+		maxDepth: DefaultMaxDepth,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
 
 	// register prefix functions by mapping them to the relevant token
@@ -66,12 +121,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.WHILE, p.parseWhileExpression)
+	p.registerPrefix(token.DO, p.parseDoWhileExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.SWITCH, p.parseSwitchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.CHAR, p.parseCharLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
@@ -81,12 +143,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.QUESTION, p.parseTernaryExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+
+	// register postfix functions by mapping them to the relevant token
+	p.postfixParseFns = make(map[token.TokenType]postfixParseFn)
+	p.registerPostfix(token.INCR, p.parsePostfixExpression)
+	p.registerPostfix(token.DECR, p.parsePostfixExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -98,9 +171,29 @@ func New(l *lexer.Lexer) *Parser {
 // nextToken advances the current and next tokens being observed
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	if len(p.lookahead) > 0 {
+		p.peekToken = p.lookahead[0]
+		p.lookahead = p.lookahead[1:]
+		return
+	}
 	p.peekToken = p.l.NextToken()
 }
 
+// peekAt returns the token n positions past the current one without
+// consuming anything: peekAt(0) is curToken and peekAt(1) is peekToken
+func (p *Parser) peekAt(n int) token.Token {
+	switch n {
+	case 0:
+		return p.curToken
+	case 1:
+		return p.peekToken
+	}
+	for len(p.lookahead) < n-1 {
+		p.lookahead = append(p.lookahead, p.l.NextToken())
+	}
+	return p.lookahead[n-2]
+}
+
 // curTokenIs determines whether the current token is of the type t
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
@@ -123,22 +216,15 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-// Errors returns the parser's string of errors
-func (p *Parser) Errors() []string {
-	return p.errors
-}
-
 // peekError appends a new error to p.errors when called
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+	p.addError(p.peekToken, "expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
 }
 
 // noPrefixParseFnError appends a new error to p.errors when called
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, "no prefix parse function for %s found", t)
 }
 
 // ParseProgram returns an ast root node containing all the parsed program's statements
@@ -146,39 +232,184 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
+	p.parseStatements(func(stmt ast.Statement) bool {
+		program.Statements = append(program.Statements, stmt)
+		return true
+	})
+
+	return program
+}
+
+// ParseExpression parses the whole input as a single expression, such as
+// 1 + 2 * 3, and returns it along with the messages of any errors found.
+// A trailing semicolon is allowed, anything else after the expression is
+// an error
+func (p *Parser) ParseExpression() (ast.Expression, []string) {
+	exp := p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if exp != nil && !p.peekTokenIs(token.EOF) {
+		p.addError(p.peekToken, "unexpected %s after expression", p.peekToken.Type)
+	}
+
+	return exp, p.Errors()
+}
+
+// ParseStream parses the input in the background, sending each statement
+// on the first channel as soon as it is parsed, so that large inputs can be
+// processed without building the whole program. Once the input is
+// exhausted the statement channel is closed, the parser errors are sent on
+// the second channel, and that is closed too. Statements are dropped when
+// they fail to parse, as with ParseProgram.
+//
+// The channels are unbuffered, so the statement channel has to be drained
+// before the errors can be read. Cancelling ctx stops the parsing early:
+// both channels are then closed without sending anything more, which lets
+// a reader give up part way without leaking the goroutine
+func (p *Parser) ParseStream(ctx context.Context) (<-chan ast.Statement, <-chan ParserError) {
+	statements := make(chan ast.Statement)
+	errors := make(chan ParserError)
+
+	go func() {
+		defer close(errors)
+
+		p.parseStatements(func(stmt ast.Statement) bool {
+			select {
+			case statements <- stmt:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		close(statements)
+
+		for _, err := range p.errors {
+			select {
+			case errors <- err:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return statements, errors
+}
+
+// parseStatements parses statements until EOF, passing each one that
+// parsed without errors to emit. It stops early when emit returns false
+func (p *Parser) parseStatements(emit func(ast.Statement) bool) {
 	for !p.curTokenIs(token.EOF) {
+		errors := len(p.errors)
 		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+		if stmt != nil && len(p.errors) == errors {
+			if !emit(stmt) {
+				return
+			}
+		} else {
+			// discard the broken statement and carry on with the next one,
+			// so that independent errors further down are reported as well
+			p.skipStatement()
 		}
 		p.nextToken()
 	}
+}
 
-	return program
+// skipStatement advances the tokens until the end of the current statement
+func (p *Parser) skipStatement() {
+	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) {
+		p.nextToken()
+	}
 }
 
-// parseStatement wraps parsing methods for statements and expressions
+// parseStatement wraps parsing methods for statements and expressions.
+// It returns nil if the statement could not be parsed
 func (p *Parser) parseStatement() ast.Statement {
+	var stmt ast.Statement
+
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if s := p.parseLetStatement(); s != nil {
+			stmt = s
+		}
+	case token.CONST:
+		if s := p.parseConstStatement(); s != nil {
+			stmt = s
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if s := p.parseReturnStatement(); s != nil {
+			stmt = s
+		}
+	case token.BREAK:
+		if s := p.parseBreakStatement(); s != nil {
+			stmt = s
+		}
+	case token.CONTINUE:
+		if s := p.parseContinueStatement(); s != nil {
+			stmt = s
+		}
 	default:
-		return p.parseExpressionStatement()
+		if s := p.parseExpressionStatement(); s != nil {
+			stmt = s
+		}
 	}
+
+	return stmt
 }
 
 // parseLetStatement returns a validated LET statement node
 // e.g.
 // let bla = 5;
+// let [a, b] = [1, 2];
+// let {name, age: years} = person;
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
+	switch {
+	case p.peekTokenIs(token.LBRACKET):
+		p.nextToken()
+		stmt.Pattern = p.parseArrayPattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	case p.peekTokenIs(token.LBRACE):
+		p.nextToken()
+		stmt.Pattern = p.parseHashPattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	default:
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseConstStatement returns a validated CONST statement node
+// e.g.
+// const max = 10;
+func (p *Parser) parseConstStatement() *ast.ConstStatement {
+	stmt := &ast.ConstStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
 	if !p.expectPeek(token.ASSIGN) {
@@ -196,6 +427,75 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parseArrayPattern returns the bracketed list of names a let statement
+// destructures an array into, optionally ending with a ...rest name
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		pattern.Elements = append(pattern.Elements, ident)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+// parseHashPattern returns the braced list of keys a let statement
+// destructures a hash into, each optionally renamed as in key: name
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		entry := &ast.HashPatternEntry{
+			Key:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+			Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			entry.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+		pattern.Entries = append(pattern.Entries, entry)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
 // parseReturnStatement returns a validated RETURN statement
 // e.g.
 // return foo;
@@ -213,6 +513,32 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// parseBreakStatement returns a BREAK statement
+// e.g.
+// break;
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseContinueStatement returns a CONTINUE statement
+// e.g.
+// continue;
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 // parseExpressionStatement returns a validated expression statement
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
@@ -226,8 +552,22 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
+// newlineEndsExpression reports whether an automatic semicolon goes
+// between the current and the next token
+func (p *Parser) newlineEndsExpression() bool {
+	return p.autoSemicolons && p.nesting == 0 &&
+		p.peekToken.Pos.Line > p.curToken.Pos.Line
+}
+
 // parseExpression returns a validated expression node
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > p.maxDepth {
+		p.abandon("nesting too deep")
+		return nil
+	}
+
 	// check if the current token's type is associated with a prefixParseFn
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
@@ -235,11 +575,32 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 	// if it is, call it
-	leftExp := prefix()
+	return p.parseOperators(prefix(), precedence)
+}
 
+// parseOperators applies the infix and postfix operators following leftExp
+// that bind tighter than precedence
+func (p *Parser) parseOperators(leftExp ast.Expression, precedence int) ast.Expression {
 	// run until we reach a semicolon or the precedence
 	// becomes larger than the precedence of the next token's type
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	for !p.peekTokenIs(token.SEMICOLON) && !p.newlineEndsExpression() &&
+		precedence < p.peekPrecedence() {
+		if p.decrementIsSubtraction(leftExp) {
+			if precedence >= SUM {
+				return leftExp
+			}
+			p.nextToken()
+			leftExp = p.parseNegatedSubtraction(leftExp)
+			continue
+		}
+
+		// check if the next token's type is associated with a postfixParseFn
+		if postfix := p.postfixParseFns[p.peekToken.Type]; postfix != nil {
+			p.nextToken()
+			leftExp = postfix(leftExp)
+			continue
+		}
+
 		// check if the next token's type is associated with an infixParseFN
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
@@ -255,6 +616,16 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
+// abandon reports a problem that stops the parse: the rest of the input
+// is skipped, along with the errors that skipping it would cause
+func (p *Parser) abandon(message string) {
+	p.addError(p.curToken, "%s", message)
+	p.abandoned = true
+	for !p.curTokenIs(token.EOF) {
+		p.nextToken()
+	}
+}
+
 // peekPrecedence simply checks if the next token's type
 // is mapped to a precedence value. If it is, it returns it.
 // If not, it returns the lowest possible precedence value
@@ -286,8 +657,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -325,6 +695,110 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parsePostfixExpression takes the operand on the left and returns a postfix expression node
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	return &ast.PostfixExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Operator: p.curToken.Literal,
+	}
+}
+
+// decrementIsSubtraction reports whether the -- after left is rather a
+// minus followed by a negative operand, as in 5--3. That is the case when
+// left cannot be decremented and an operand follows
+func (p *Parser) decrementIsSubtraction(left ast.Expression) bool {
+	if !p.peekTokenIs(token.DECR) || left == nil {
+		return false
+	}
+	if _, ok := left.(*ast.Identifier); ok {
+		return false
+	}
+	return p.prefixParseFns[p.peekAt(2).Type] != nil
+}
+
+// parseNegatedSubtraction returns left minus the negated operand after the
+// current -- token, parsed as if the two minus signs were apart
+func (p *Parser) parseNegatedSubtraction(left ast.Expression) ast.Expression {
+	minus := token.Token{Type: token.MINUS, Literal: "-", Pos: p.curToken.Pos}
+	negation := minus
+	negation.Pos.Column++
+
+	p.nextToken()
+	operand := &ast.PrefixExpression{
+		Token:    negation,
+		Operator: "-",
+		Right:    p.parseExpression(PREFIX),
+	}
+
+	return &ast.InfixExpression{
+		Token:    minus,
+		Left:     left,
+		Operator: "-",
+		Right:    p.parseOperators(operand, SUM),
+	}
+}
+
+// parseAssignExpression returns an assignment to the identifier on the left.
+// Assignment is right associative, so a = b = 5 assigns 5 to both a and b
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		if left == nil {
+			return nil
+		}
+		p.addError(p.curToken, "cannot assign to %s", left.String())
+		return nil
+	}
+
+	expression := &ast.AssignExpression{Token: p.curToken, Name: name}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
+// parseTernaryExpression returns a conditional expression with condition on
+// the left. It is right associative, so a ? b : c ? d : e is read as
+// a ? b : (c ? d : e)
+func (p *Parser) parseTernaryExpression(condition ast.Expression) ast.Expression {
+	expression := &ast.TernaryExpression{Token: p.curToken, Condition: condition}
+
+	p.nesting++
+	p.nextToken()
+	expression.Consequence = p.parseExpression(LOWEST)
+	p.nesting--
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Alternative = p.parseExpression(TERNARY - 1)
+
+	return expression
+}
+
+// parsePipeExpression rewrites x |> f into the call f(x). When the right
+// side is already a call, x becomes its first argument, so that
+// arr |> map(f) reads as map(arr, f)
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	p.nextToken()
+	right := p.parseExpression(PIPE)
+	if right == nil {
+		return nil
+	}
+
+	if call, ok := right.(*ast.CallExpression); ok {
+		call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+		return call
+	}
+	return &ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{left}}
+}
+
 // parseBoolean returns a boolean expression
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
@@ -332,6 +806,9 @@ func (p *Parser) parseBoolean() ast.Expression {
 
 // parseGroupedExpression returns a grouped expression
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.nesting++
+	defer func() { p.nesting-- }()
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
@@ -351,8 +828,10 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		return nil
 	}
 
+	p.nesting++
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
+	p.nesting--
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
@@ -367,6 +846,16 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		// else if (...) { ... } is read as else { if (...) { ... } }
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			expression.Alternative = p.parseElseIf()
+			if expression.Alternative == nil {
+				return nil
+			}
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -377,11 +866,199 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseElseIf returns a block holding the if expression that starts at
+// the current token
+func (p *Parser) parseElseIf() *ast.BlockStatement {
+	tok := p.curToken
+
+	nested := p.parseIfExpression()
+	if nested == nil {
+		return nil
+	}
+
+	return &ast.BlockStatement{
+		Token:      tok,
+		Statements: []ast.Statement{&ast.ExpressionStatement{Token: tok, Expression: nested}},
+	}
+}
+
+// parseSwitchExpression returns a Switch expression
+// e.g.
+// switch (x) { case 1 { "one" } default { "many" } }
+func (p *Parser) parseSwitchExpression() ast.Expression {
+	expression := &ast.SwitchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nesting++
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	p.nesting--
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		switch p.curToken.Type {
+		case token.CASE:
+			c := &ast.SwitchCase{Token: p.curToken}
+			p.nextToken()
+			c.Value = p.parseExpression(LOWEST)
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			c.Body = p.parseBlockStatement()
+			expression.Cases = append(expression.Cases, c)
+		case token.DEFAULT:
+			if expression.Default != nil {
+				p.addError(p.curToken, "multiple defaults in switch")
+				return nil
+			}
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			expression.Default = p.parseBlockStatement()
+		default:
+			p.addError(p.curToken, "expected case or default, got %s instead", p.curToken.Type)
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return expression
+}
+
+// parseWhileExpression returns a While expression
+func (p *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nesting++
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	p.nesting--
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	return expression
+}
+
+// parseForExpression returns a For expression
+func (p *Parser) parseForExpression() ast.Expression {
+	expression := &ast.ForExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nesting++
+	p.nextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		switch p.curToken.Type {
+		case token.LET:
+			if s := p.parseLetStatement(); s != nil {
+				expression.Init = s
+			}
+		default:
+			if s := p.parseExpressionStatement(); s != nil {
+				expression.Init = s
+			}
+		}
+		// the statements read their semicolon when there is one
+		if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
+			p.nesting--
+			return nil
+		}
+	}
+
+	p.nextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		expression.Condition = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.SEMICOLON) {
+			p.nesting--
+			return nil
+		}
+	}
+
+	p.nextToken()
+	if !p.curTokenIs(token.RPAREN) {
+		expression.Update = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.RPAREN) {
+			p.nesting--
+			return nil
+		}
+	}
+	p.nesting--
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	return expression
+}
+
+// parseDoWhileExpression returns a DoWhile expression
+func (p *Parser) parseDoWhileExpression() ast.Expression {
+	expression := &ast.DoWhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(token.WHILE) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nesting++
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	p.nesting--
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return expression
+}
+
 // parseBlockStatement returns a block statement
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	// statements inside the block end at line breaks again
+	nesting := p.nesting
+	p.nesting = 0
+	defer func() { p.nesting = nesting }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
@@ -403,7 +1080,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.Defaults = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -414,32 +1091,70 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-// parseFunctionParameters deals with naming function parameters by returning a slice of Identifiers
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseMacroLiteral returns a macro literal expression
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+	if !p.expectPeek(token.LPAREN) {
+		return nil
 	}
 
-	p.nextToken()
+	var defaults map[string]ast.Expression
+	lit.Parameters, defaults = p.parseFunctionParameters()
+	if len(defaults) > 0 {
+		p.addError(lit.Token, "macro parameters cannot have default values")
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	lit.Body = p.parseBlockStatement()
 
-	for p.peekTokenIs(token.COMMA) {
+	return lit
+}
+
+// parseFunctionParameters deals with naming function parameters by returning a slice of Identifiers,
+// along with the default values of the optional ones keyed by parameter name
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, map[string]ast.Expression) {
+	identifiers := []*ast.Identifier{}
+	var defaults map[string]ast.Expression
+
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return identifiers, defaults
+	}
+
+	for {
 		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			p.nesting++
+			def := p.parseExpression(LOWEST)
+			p.nesting--
+			if defaults == nil {
+				defaults = map[string]ast.Expression{}
+			}
+			defaults[ident.Value] = def
+		} else if len(defaults) > 0 {
+			p.addError(ident.Token, "required parameter %s follows an optional parameter", ident.Value)
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	return identifiers, defaults
 }
 
 // parseCallExpression return a call expression
@@ -459,10 +1174,26 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+// registerPostfix is a wrapper to map a postfixParseFn to a token type
+func (p *Parser) registerPostfix(tokenType token.TokenType, fn postfixParseFn) {
+	p.postfixParseFns[tokenType] = fn
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseCharLiteral returns a char literal, decoding its escape sequence if
+// it has one. The literal must hold exactly one character
+func (p *Parser) parseCharLiteral() ast.Expression {
+	value, _, tail, err := strconv.UnquoteChar(p.curToken.Literal, '\'')
+	if err != nil || tail != "" {
+		p.addError(p.curToken, "invalid char literal '%s'", p.curToken.Literal)
+		return nil
+	}
+	return &ast.CharLiteral{Token: p.curToken, Value: value}
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
@@ -471,6 +1202,9 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 }
 
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	p.nesting++
+	defer func() { p.nesting-- }()
+
 	list := []ast.Expression{}
 
 	if p.peekTokenIs(end) {
@@ -482,6 +1216,10 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
+		// allow a trailing comma before the closing delimiter
+		if p.peekTokenIs(end) {
+			break
+		}
 		p.nextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -492,11 +1230,43 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseIndexExpression returns an index expression, or a slice expression
+// if the brackets contain a colon
+// e.g.
+// a[1] or a[1:3]
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nesting++
+	defer func() { p.nesting-- }()
+
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+// parseSliceExpression returns a slice expression starting at low,
+// with the current token being the colon
+func (p *Parser) parseSliceExpression(tok token.Token, left, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
+	}
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
@@ -507,6 +1277,9 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
+	p.nesting++
+	defer func() { p.nesting-- }()
+
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
@@ -520,6 +1293,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 		hash.Pairs[key] = value
 
+		// a trailing comma is consumed here and ends the loop
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +44,166 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      interface{}
+	}{
+		{"const x = 5;", "x", 5},
+		{"const y = true", "y", true},
+		{"const foobar = y;", "foobar", "y"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ConstStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ConstStatement. got=%T", program.Statements[0])
+		}
+		if stmt.TokenLiteral() != "const" {
+			t.Errorf("stmt.TokenLiteral not 'const'. got=%q", stmt.TokenLiteral())
+		}
+		testIdentifier(t, stmt.Name, tt.expectedIdentifier)
+		testLiteralExpression(t, stmt.Value, tt.expectedValue)
+	}
+}
+
+func TestConstStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const = 5;", "expected next token to be IDENT, got = instead"},
+		{"const x 5;", "expected next token to be =, got INT instead"},
+		{"const [a] = b;", "expected next token to be IDENT, got [ instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestArrayPatternLetStatements(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedElements []string
+		expectedRest     string
+	}{
+		{"let [a, b, c] = [1, 2, 3];", []string{"a", "b", "c"}, ""},
+		{"let [head, ...tail] = arr;", []string{"head"}, "tail"},
+		{"let [...all] = arr;", nil, "all"},
+		{"let [] = arr;", nil, ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+		pattern, ok := stmt.Pattern.(*ast.ArrayPattern)
+		if !ok {
+			t.Fatalf("stmt.Pattern not *ast.ArrayPattern. got=%T", stmt.Pattern)
+		}
+		if stmt.Name != nil {
+			t.Errorf("stmt.Name set along with a pattern. got=%s", stmt.Name)
+		}
+
+		if len(pattern.Elements) != len(tt.expectedElements) {
+			t.Fatalf("wrong number of elements. want=%d, got=%d", len(tt.expectedElements), len(pattern.Elements))
+		}
+		for i, name := range tt.expectedElements {
+			testIdentifier(t, pattern.Elements[i], name)
+		}
+		switch {
+		case tt.expectedRest == "" && pattern.Rest != nil:
+			t.Errorf("unexpected rest element %s", pattern.Rest)
+		case tt.expectedRest != "":
+			testIdentifier(t, pattern.Rest, tt.expectedRest)
+		}
+		if stmt.String() != tt.input {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.input, stmt.String())
+		}
+	}
+}
+
+func TestHashPatternLetStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKeys  []string
+		expectedNames []string
+	}{
+		{"let {name, age} = person;", []string{"name", "age"}, []string{"name", "age"}},
+		{"let {name: n, age} = person;", []string{"name", "age"}, []string{"n", "age"}},
+		{"let {} = person;", nil, nil},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.LetStatement)
+		pattern, ok := stmt.Pattern.(*ast.HashPattern)
+		if !ok {
+			t.Fatalf("stmt.Pattern not *ast.HashPattern. got=%T", stmt.Pattern)
+		}
+
+		if len(pattern.Entries) != len(tt.expectedKeys) {
+			t.Fatalf("wrong number of entries. want=%d, got=%d", len(tt.expectedKeys), len(pattern.Entries))
+		}
+		for i, entry := range pattern.Entries {
+			testIdentifier(t, entry.Key, tt.expectedKeys[i])
+			testIdentifier(t, entry.Name, tt.expectedNames[i])
+		}
+		if stmt.String() != tt.input {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.input, stmt.String())
+		}
+	}
+}
+
+func TestArrayPatternErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, 1] = arr;", "expected next token to be IDENT, got INT instead"},
+		{"let [...rest, a] = arr;", "expected next token to be ], got , instead"},
+		{"let [a b] = arr;", "expected next token to be ], got IDENT instead"},
+		{"let [a] 5;", "expected next token to be =, got INT instead"},
+		{`let {"a"} = h;`, "expected next token to be IDENT, got STRING instead"},
+		{"let {a: 1} = h;", "expected next token to be IDENT, got INT instead"},
+		{"let {a b} = h;", "expected next token to be }, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -151,6 +314,8 @@ func TestParsingPrefixExpressions(t *testing.T) {
 		{"-foobar;", "-", "foobar"},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
+		{"~5;", "~", 5},
+		{"~foobar;", "~", "foobar"},
 	}
 
 	for _, tt := range prefixTests {
@@ -195,6 +360,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
@@ -245,6 +411,78 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-a * b",
 			"((-a) * b)",
 		},
+		{
+			"~a + b",
+			"((~a) + b)",
+		},
+		{
+			"a ? b : c",
+			"(a ? b : c)",
+		},
+		{
+			"a ? b : c ? d : e",
+			"(a ? b : (c ? d : e))",
+		},
+		{
+			"a ? b ? c : d : e",
+			"(a ? (b ? c : d) : e)",
+		},
+		{
+			"a < b ? a + 1 : b * 2",
+			"((a < b) ? (a + 1) : (b * 2))",
+		},
+		{
+			"x = a == b ? c : d",
+			"x = ((a == b) ? c : d)",
+		},
+		{
+			"f(a ? b : c, d)",
+			"f((a ? b : c), d)",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a && b && c",
+			"((a && b) && c)",
+		},
+		{
+			"a < b && c == d || !e",
+			"(((a < b) && (c == d)) || (!e))",
+		},
+		{
+			"a || b ? c : d",
+			"((a || b) ? c : d)",
+		},
+		{
+			"x = a && b",
+			"x = (a && b)",
+		},
+		{
+			"-a++",
+			"(-(a++))",
+		},
+		{
+			"a++ + b--",
+			"((a++) + (b--))",
+		},
+		{
+			"5--3",
+			"(5 - (-3))",
+		},
+		{
+			"2 * 5--3 * 2",
+			"((2 * 5) - ((-3) * 2))",
+		},
+		{
+			"f(1)--a",
+			"(f(1) - (-a))",
+		},
 		{
 			"!-a",
 			"(!(-a))",
@@ -265,6 +503,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * b / c",
 			"((a * b) / c)",
 		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a[1 + 1:b * 2]",
+			"(a[(1 + 1):(b * 2)])",
+		},
 		{
 			"a + b / c",
 			"(a + (b / c))",
@@ -509,6 +755,154 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("exp.Alternative does not hold a single statement. got=%+v", exp.Alternative)
+	}
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("alternative is not ast.ExpressionStatement. got=%T",
+			exp.Alternative.Statements[0])
+	}
+	elseIf, ok := alternative.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression. got=%T", alternative.Expression)
+	}
+	if !testInfixExpression(t, elseIf.Condition, "x", ">", "y") {
+		return
+	}
+	if elseIf.Alternative == nil || elseIf.Alternative.String() != "z" {
+		t.Errorf("innermost alternative is not z. got=%+v", elseIf.Alternative)
+	}
+
+	expected := "if(x < y) xelse if(x > y) yelse z"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestElseIfErrors(t *testing.T) {
+	tests := []string{
+		"if (x) { 1 } else if { 2 }",
+		"if (x) { 1 } else if (y) 2",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestSwitchExpression(t *testing.T) {
+	input := `switch (x) { case 1 { "one" } case y + 1 { "two" } default { "many" } }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.SwitchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.SwitchExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Subject, "x") {
+		return
+	}
+
+	if len(exp.Cases) != 2 {
+		t.Fatalf("switch does not have 2 cases. got=%d", len(exp.Cases))
+	}
+	testLiteralExpression(t, exp.Cases[0].Value, 1)
+	testInfixExpression(t, exp.Cases[1].Value, "y", "+", 1)
+
+	for i, want := range []string{"one", "two"} {
+		if body := exp.Cases[i].Body.String(); body != want {
+			t.Errorf("case %d body wrong. want=%q, got=%q", i, want, body)
+		}
+	}
+
+	if exp.Default == nil || exp.Default.String() != "many" {
+		t.Errorf("default body wrong. got=%+v", exp.Default)
+	}
+}
+
+func TestSwitchExpressionWithoutDefault(t *testing.T) {
+	p := New(lexer.New(`switch (x) { case 1 { 2 } }; y`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SwitchExpression)
+	if len(exp.Cases) != 1 || exp.Default != nil {
+		t.Errorf("wrong switch structure. got=%q", exp.String())
+	}
+}
+
+func TestSwitchExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`switch (x) { 1 { 2 } }`, "expected case or default, got INT instead"},
+		{`switch (x) { default { 1 } default { 2 } }`, "multiple defaults in switch"},
+		{`switch x { case 1 { 2 } }`, "expected next token to be (, got IDENT instead"},
+		{`switch (x) { case 1 2 }`, "expected next token to be {, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -556,6 +950,53 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -586,15 +1027,82 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
-func TestCallExpressionParsing(t *testing.T) {
-	input := "add(1, 2 * 3, 4 + 5);"
+func TestDefaultParameterParsing(t *testing.T) {
+	input := `fn(x, y = 10, z = x * 2) { x + y + z }`
 
-	l := lexer.New(input)
-	p := New(l)
+	p := New(lexer.New(input))
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
-	if len(program.Statements) != 1 {
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.Parameters) != 3 {
+		t.Fatalf("length parameters wrong. want 3, got=%d", len(function.Parameters))
+	}
+	if _, ok := function.Defaults["x"]; ok {
+		t.Errorf("required parameter x has a default")
+	}
+	testLiteralExpression(t, function.Defaults["y"], 10)
+	testInfixExpression(t, function.Defaults["z"], "x", "*", 2)
+
+	if got := function.String(); got != "fn(x, y = 10, z = (x * 2)) ((x + y) + z)" {
+		t.Errorf("function.String() wrong. got=%q", got)
+	}
+}
+
+func TestDefaultParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn(x = 1, y) { x }`, "required parameter y follows an optional parameter"},
+		{`macro(x = 1) { x }`, "macro parameters cannot have default values"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestPipeExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x |> f", "f(x)"},
+		{"x |> f |> g", "g(f(x))"},
+		{"arr |> map(double) |> filter(even)", "filter(map(arr, double), even)"},
+		{"1 + 2 |> f", "f((1 + 2))"},
+		{"x |> fn(a) { a * 2 }", "fn(a) (a * 2)(x)"},
+		{"y = x |> f", "y = f(x)"},
+		{"g(x |> f, 1)", "g(f(x), 1)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong rewrite of %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
 		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
 			1, len(program.Statements))
 	}
@@ -985,3 +1493,779 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestCharLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected rune
+	}{
+		{`'a'`, 'a'},
+		{`'A'`, 'A'},
+		{`'\n'`, '\n'},
+		{`'\''`, '\''},
+		{`'\\'`, '\\'},
+		{`'é'`, 'é'},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.CharLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.CharLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %q. got=%q", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestCharLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`''`, "invalid char literal ''"},
+		{`'ab'`, "invalid char literal 'ab'"},
+		{`'\q'`, "invalid char literal '\\q'"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %s. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3]`, `[1, 2, 3]`},
+		{`[1, 2, 3,]`, `[1, 2, 3]`},
+		{`add(1, 2)`, `add(1, 2)`},
+		{`add(1, 2,)`, `add(1, 2)`},
+		{`{"a": 1}`, `{a:1}`},
+		{`{"a": 1,}`, `{a:1}`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestTrailingCommaErrors(t *testing.T) {
+	tests := []string{
+		`[1, 2,,]`,
+		`[,]`,
+		`add(,)`,
+		`{"a": 1,,}`,
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedName  string
+		expectedValue interface{}
+	}{
+		{"x = 10;", "x", 10},
+		{"y = true;", "y", true},
+		{"foobar = y;", "foobar", "y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if !testIdentifier(t, exp.Name, tt.expectedName) {
+			return
+		}
+		if !testLiteralExpression(t, exp.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestAssignExpressionAssociativity(t *testing.T) {
+	input := "a = b = 1 + 2"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T",
+			stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Name, "a") {
+		return
+	}
+
+	inner, ok := exp.Value.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("exp.Value is not ast.AssignExpression. got=%T", exp.Value)
+	}
+	if !testIdentifier(t, inner.Name, "b") {
+		return
+	}
+	testInfixExpression(t, inner.Value, 1, "+", 2)
+}
+
+func TestAssignToNonIdentifier(t *testing.T) {
+	l := lexer.New("5 = 10;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. got=%d (%v)", len(errors), errors)
+	}
+	if errors[0] != "cannot assign to 5" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+}
+
+func TestWhileExpression(t *testing.T) {
+	input := `while (x < y) { x }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.WhileExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statements. got=%d\n",
+			len(exp.Body.Statements))
+	}
+
+	body, ok := exp.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Body.Statements[0])
+	}
+
+	if !testIdentifier(t, body.Expression, "x") {
+		return
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	input := `for (let i = 0; i < 10; i = i + 1) { sum = sum + i }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T",
+			stmt.Expression)
+	}
+
+	init, ok := exp.Init.(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("exp.Init is not ast.LetStatement. got=%T", exp.Init)
+	}
+	if !testLetStatement(t, init, "i") || !testIntegerLiteral(t, init.Value, 0) {
+		return
+	}
+
+	if !testInfixExpression(t, exp.Condition, "i", "<", 10) {
+		return
+	}
+
+	if exp.Update.String() != "i = (i + 1)" {
+		t.Errorf("exp.Update wrong. got=%q", exp.Update.String())
+	}
+
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d\n",
+			len(exp.Body.Statements))
+	}
+}
+
+func TestForExpressionOptionalParts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (;;) { break; }", "for (; ; ) break;"},
+		{"for (i = 0; ; i++) { }", "for (i = 0; ; (i++)) "},
+		{"for (;i < 3;) { i }", "for (; (i < 3); ) i"},
+		{"for (let i = 0\n; i < 3; i++) { i }", "for (let i = 0; (i < 3); (i++)) i"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement for %q. got=%d",
+				tt.input, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestForExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for i { }", "expected next token to be (, got IDENT instead"},
+		{"for (let i = 0 i < 3; i++) { }", "expected next token to be ;, got IDENT instead"},
+		{"for (;; i++ { }", "expected next token to be ), got { instead"},
+		{"for (;;) i", "expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestDoWhileExpression(t *testing.T) {
+	input := "do { x }\nwhile (x < y); z"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			2, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.DoWhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.DoWhileExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d\n",
+			len(exp.Body.Statements))
+	}
+
+	body, ok := exp.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Body.Statements[0])
+	}
+
+	if !testIdentifier(t, body.Expression, "x") {
+		return
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+}
+
+func TestDoWhileExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"do { x }", "expected next token to be WHILE, got EOF instead"},
+		{"do { x } while x", "expected next token to be (, got IDENT instead"},
+		{"do x while (y)", "expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestBreakContinueStatements(t *testing.T) {
+	input := `while (true) { break; continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.WhileExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if len(exp.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n",
+			len(exp.Body.Statements))
+	}
+	if _, ok := exp.Body.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[0] is not ast.BreakStatement. got=%T",
+			exp.Body.Statements[0])
+	}
+	if _, ok := exp.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[1] is not ast.ContinueStatement. got=%T",
+			exp.Body.Statements[1])
+	}
+}
+
+func TestParsingPostfixExpressions(t *testing.T) {
+	postfixTests := []struct {
+		input    string
+		operator string
+		value    interface{}
+	}{
+		{"i++;", "++", "i"},
+		{"i--;", "--", "i"},
+	}
+
+	for _, tt := range postfixTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.PostfixExpression)
+		if !ok {
+			t.Fatalf("stmt is not ast.PostfixExpression. got=%T", stmt.Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Fatalf("exp.Operator is not '%s'. got=%s",
+				tt.operator, exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Left, tt.value) {
+			return
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	input := `
+let = 5;
+let y 10;
+let z = 3;
+`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 2 {
+		t.Fatalf("wrong number of errors. got=%d (%v)", len(errors), errors)
+	}
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+			len(program.Statements))
+	}
+
+	if !testLetStatement(t, program.Statements[0], "z") {
+		return
+	}
+
+	for _, stmt := range program.Statements {
+		if stmt == nil {
+			t.Fatalf("program.Statements contains a nil statement")
+		}
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLow  interface{}
+		expectedHigh interface{}
+	}{
+		{"a[1:3]", 1, 3},
+		{"a[:2]", nil, 2},
+		{"a[1:]", 1, nil},
+		{"a[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if !testIdentifier(t, slice.Left, "a") {
+			return
+		}
+
+		for _, bound := range []struct {
+			exp      ast.Expression
+			expected interface{}
+		}{{slice.Low, tt.expectedLow}, {slice.High, tt.expectedHigh}} {
+			if bound.expected == nil {
+				if bound.exp != nil {
+					t.Errorf("%s: bound is not nil. got=%s", tt.input, bound.exp)
+				}
+				continue
+			}
+			testLiteralExpression(t, bound.exp, bound.expected)
+		}
+	}
+}
+
+func TestPeekAt(t *testing.T) {
+	input := "let x = 5;"
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.EOF, token.EOF}
+
+	p := New(lexer.New(input))
+
+	if p.peekAt(0) != p.curToken {
+		t.Errorf("peekAt(0) is not curToken. got=%+v", p.peekAt(0))
+	}
+	if p.peekAt(1) != p.peekToken {
+		t.Errorf("peekAt(1) is not peekToken. got=%+v", p.peekAt(1))
+	}
+	for i, want := range expected {
+		if got := p.peekAt(i).Type; got != want {
+			t.Errorf("peekAt(%d) wrong. want=%q, got=%q", i, want, got)
+		}
+	}
+
+	// consuming tokens after peeking ahead yields them in order
+	for i, want := range expected[:len(expected)-1] {
+		if p.curToken.Type != want {
+			t.Errorf("token %d wrong after peeking. want=%q, got=%q", i, want, p.curToken.Type)
+		}
+		if p.peekAt(1) != p.peekToken {
+			t.Errorf("peekAt(1) is not peekToken at token %d", i)
+		}
+		p.nextToken()
+	}
+}
+
+func TestPeekAtKeepsParsingUnchanged(t *testing.T) {
+	input := "let add = fn(a, b) { a + b }; add(1, [2, 3][0]);"
+
+	want := New(lexer.New(input)).ParseProgram().String()
+
+	p := New(lexer.New(input))
+	p.peekAt(10)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != want {
+		t.Errorf("program changed by peeking ahead. want=%q, got=%q", want, program.String())
+	}
+}
+
+func TestAutoSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 5\nx", []string{"let x = 5;", "x"}},
+		{"x\n-1", []string{"x", "(-1)"}},
+		{"f\n(1)", []string{"f", "1"}},
+		{"1 +\n2", []string{"(1 + 2)"}},
+		{"(1\n+ 2)", []string{"(1 + 2)"}},
+		{"add(1,\n2)\n[3,\n4]", []string{"add(1, 2)", "[3, 4]"}},
+		{"let f = fn(x) {\n  let y = x\n  y\n  -1\n}\nf(1)",
+			[]string{"let f = fn(x) let y = x;y(-1);", "f(1)"}},
+		{"if (x\n> 1) { x }\ny", []string{"if(x > 1) x", "y"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input), AutoSemicolons())
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != len(tt.expected) {
+			t.Errorf("%q: wrong number of statements. want=%d, got=%d (%q)",
+				tt.input, len(tt.expected), len(program.Statements), program.String())
+			continue
+		}
+		for i, want := range tt.expected {
+			if got := program.Statements[i].String(); got != want {
+				t.Errorf("%q: statement %d wrong. want=%q, got=%q", tt.input, i, want, got)
+			}
+		}
+	}
+}
+
+func TestWithoutAutoSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x\n-1", "(x - 1)"},
+		{"f\n(1)", "f(1)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 || program.String() != tt.expected {
+			t.Errorf("%q: want a single statement %q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	exp, errors := New(lexer.New("1 + 2 * 3")).ParseExpression()
+	if len(errors) != 0 {
+		t.Fatalf("unexpected parser errors: %q", errors)
+	}
+
+	infix, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("exp is not *ast.InfixExpression. got=%T", exp)
+	}
+	testIntegerLiteral(t, infix.Left, 1)
+	if infix.Operator != "+" {
+		t.Errorf("wrong operator. want=+, got=%s", infix.Operator)
+	}
+	testInfixExpression(t, infix.Right, 2, "*", 3)
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 3", "unexpected INT after expression"},
+		{"1 +", "no prefix parse function for EOF found"},
+		{"let x = 1", "no prefix parse function for LET found"},
+	}
+
+	for _, tt := range tests {
+		_, errors := New(lexer.New(tt.input)).ParseExpression()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. want first=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+
+	if _, errors := New(lexer.New("x;")).ParseExpression(); len(errors) != 0 {
+		t.Errorf("trailing semicolon rejected: %q", errors)
+	}
+}
+
+func TestParseStream(t *testing.T) {
+	input := `
+let x = 5;
+let add = fn(a, b) { a + b };
+add(x, 10);
+if (x > 1) { x } else { 0 }
+`
+	program := New(lexer.New(input)).ParseProgram()
+
+	statements, errors := New(lexer.New(input)).ParseStream(context.Background())
+	var streamed []ast.Statement
+	for stmt := range statements {
+		streamed = append(streamed, stmt)
+	}
+	for err := range errors {
+		t.Errorf("parser error: %q", err.Error())
+	}
+
+	if len(streamed) != len(program.Statements) {
+		t.Fatalf("wrong number of statements. want=%d, got=%d", len(program.Statements), len(streamed))
+	}
+	for i, stmt := range streamed {
+		if stmt.String() != program.Statements[i].String() {
+			t.Errorf("statement %d wrong. want=%q, got=%q", i, program.Statements[i].String(), stmt.String())
+		}
+	}
+}
+
+func TestParseStreamErrors(t *testing.T) {
+	input := `let x = 5; let = 10; x;`
+
+	statements, errors := New(lexer.New(input)).ParseStream(context.Background())
+	var streamed []string
+	for stmt := range statements {
+		streamed = append(streamed, stmt.String())
+	}
+	var messages []string
+	for err := range errors {
+		messages = append(messages, err.Error())
+	}
+
+	if len(streamed) != 2 || streamed[0] != "let x = 5;" || streamed[1] != "x" {
+		t.Errorf("wrong statements. got=%q", streamed)
+	}
+	if len(messages) == 0 || messages[0] != "expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong errors. got=%q", messages)
+	}
+}
+
+func TestParseStreamCancel(t *testing.T) {
+	input := strings.Repeat("let = 1; x;", 1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	statements, errors := New(lexer.New(input)).ParseStream(ctx)
+	if stmt := <-statements; stmt == nil || stmt.String() != "x" {
+		t.Fatalf("wrong first statement. got=%v", stmt)
+	}
+	cancel()
+
+	// both channels are closed soon after, without the rest being read
+	streamed := 1
+	for range statements {
+		streamed++
+	}
+	for range errors {
+	}
+	if streamed == 1000 {
+		t.Errorf("parsing did not stop when the context was cancelled")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	deep := strings.Repeat("(", 100000) + "1" + strings.Repeat(")", 100000)
+	p := New(lexer.New(deep))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0] != "nesting too deep" {
+		t.Errorf("wrong errors for deeply nested input. got=%q", errors)
+	}
+
+	tests := []struct {
+		input   string
+		tooDeep bool
+	}{
+		{"((1))", false},
+		{"(((1)))", true},
+		{"-(1)", false},
+		{"-(-1)", true},
+		{"[[1]]; [[2]]", false},
+		{"[[[1]]]; 2", true},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input), MaxDepth(3))
+		p.ParseProgram()
+		errors := p.Errors()
+		if tt.tooDeep && (len(errors) != 1 || errors[0] != "nesting too deep") {
+			t.Errorf("wrong errors for %q. want=%q, got=%q", tt.input, "nesting too deep", errors)
+		}
+		if !tt.tooDeep && len(errors) != 0 {
+			t.Errorf("unexpected parser errors for %q: %q", tt.input, errors)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package repl
+
+import (
+	"io"
+	"monkey/lexer"
+	"monkey/token"
+	"os"
+	"strings"
+)
+
+// ANSI escape codes used to color the REPL output
+const (
+	colorReset    = "\x1b[0m"
+	colorKeyword  = "\x1b[35m" // magenta
+	colorNumber   = "\x1b[33m" // yellow
+	colorString   = "\x1b[32m" // green
+	colorOperator = "\x1b[36m" // cyan
+	colorError    = "\x1b[31m" // red
+)
+
+// token types highlighted as operators
+var operators = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.PERCENT:  true,
+	token.TILDE:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.INCR:     true,
+	token.DECR:     true,
+	token.PIPE:     true,
+	token.AND:      true,
+	token.OR:       true,
+	token.QUESTION: true,
+	token.COLON:    true,
+}
+
+// painter colors the REPL output, or leaves it untouched when disabled
+type painter struct {
+	enabled bool
+}
+
+// isTerminal reports whether out writes to a terminal
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// highlight colors the keywords, numbers, strings and operators of src,
+// using the lexer to tell them apart. Source the lexer rejects is left as
+// it is
+func (p painter) highlight(src string) string {
+	if !p.enabled {
+		return src
+	}
+
+	l := lexer.New(src, lexer.PreserveWhitespace())
+	var out strings.Builder
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch {
+		case tok.Type == token.STRING:
+			out.WriteString(colorString + `"` + tok.Literal + `"` + colorReset)
+		case tok.Type == token.CHAR:
+			out.WriteString(colorNumber + "'" + tok.Literal + "'" + colorReset)
+		case tok.Type == token.INT:
+			out.WriteString(colorNumber + tok.Literal + colorReset)
+		case tok.Type != token.IDENT && token.LookupIdent(tok.Literal) == tok.Type:
+			out.WriteString(colorKeyword + tok.Literal + colorReset)
+		case operators[tok.Type]:
+			out.WriteString(colorOperator + tok.Literal + colorReset)
+		default:
+			out.WriteString(tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		return src
+	}
+	return out.String()
+}
+
+// string colors s as a string value
+func (p painter) string(s string) string {
+	return p.paint(colorString, s)
+}
+
+// error colors msg as an error
+func (p painter) error(msg string) string {
+	return p.paint(colorError, msg)
+}
+
+func (p painter) paint(color, s string) string {
+	if !p.enabled {
+		return s
+	}
+	return color + s + colorReset
+}
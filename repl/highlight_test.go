@@ -0,0 +1,58 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNoColor(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let f = fn(x) { x + 1 };\nf\n\"a\"\n1 + true\n"), &out, Quiet(), NoColor())
+	output := out.String()
+
+	if strings.Contains(output, "\x1b[") {
+		t.Errorf("output contains escape codes. got=%q", output)
+	}
+	for _, want := range []string{"fn(x) {\n(x + 1)\n}\n", "a\n", "ERROR: 1:1: type mismatch: INTEGER + BOOLEAN\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q. got=%q", want, output)
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let f = fn(x) { x + 1 };\nf\n\"a\"\n1 + true\n"), &out, Quiet(), Color())
+	output := out.String()
+
+	for _, want := range []string{
+		colorKeyword + "fn" + colorReset,
+		colorOperator + "+" + colorReset,
+		colorNumber + "1" + colorReset,
+		colorString + "a" + colorReset,
+		colorError + "ERROR: 1:1: type mismatch: INTEGER + BOOLEAN" + colorReset,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q. got=%q", want, output)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	paint := painter{enabled: true}
+
+	got := paint.highlight(`if (x == "y") { 'c' }`)
+	want := colorKeyword + "if" + colorReset + " (x " + colorOperator + "==" + colorReset + " " +
+		colorString + `"y"` + colorReset + ") { " + colorNumber + "'c'" + colorReset + " }"
+	if got != want {
+		t.Errorf("highlight wrong.\nwant=%q\ngot =%q", want, got)
+	}
+
+	if got := paint.highlight("a $ b"); got != "a $ b" {
+		t.Errorf("source with lexer errors was changed. got=%q", got)
+	}
+	if got := (painter{}).highlight("let x = 1"); got != "let x = 1" {
+		t.Errorf("disabled painter changed its input. got=%q", got)
+	}
+}
@@ -0,0 +1,215 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultHistorySize is the number of lines a REPL session remembers
+const DefaultHistorySize = 1000
+
+// History keeps the lines entered in the REPL, oldest first, dropping the
+// oldest ones once it holds max lines
+type History struct {
+	lines []string
+	max   int
+	file  io.Writer // where recorded lines are appended as they come, if anywhere
+}
+
+// NewHistory returns an empty History remembering up to max lines
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// Add appends line to the history. Blank lines and repeats of the latest
+// line are not recorded
+func (h *History) Add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if h.file != nil {
+		io.WriteString(h.file, line+"\n")
+	}
+	if h.max > 0 && len(h.lines) > h.max {
+		h.lines = h.lines[len(h.lines)-h.max:]
+	}
+}
+
+// Lines returns the recorded lines, oldest first
+func (h *History) Lines() []string {
+	return h.lines
+}
+
+// LoadHistory returns a History remembering up to max lines, filled with
+// the last lines saved at path. A missing or unreadable file gives an
+// empty history, and lines that are not valid text are skipped
+func LoadHistory(path string, max int) *History {
+	h := NewHistory(max)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !utf8.ValidString(line) || strings.ContainsRune(line, escapePrefix) {
+			continue
+		}
+		h.Add(strings.TrimSuffix(line, "\r"))
+	}
+	return h
+}
+
+// Save writes the history to path, one line per entry
+func (h *History) Save(path string) error {
+	var out strings.Builder
+	for _, line := range h.lines {
+		out.WriteString(line + "\n")
+	}
+	return os.WriteFile(path, []byte(out.String()), 0600)
+}
+
+// LineReader reads the lines entered into the REPL one at a time,
+// returning io.EOF once there are no more
+type LineReader interface {
+	ReadLine() (string, error)
+}
+
+// escape sequences sent by the arrow keys, in normal and application
+// cursor mode
+const (
+	keyUp        = "\x1b[A"
+	keyDown      = "\x1b[B"
+	keyUpApp     = "\x1bOA"
+	keyDownApp   = "\x1bOB"
+	escapePrefix = '\x1b'
+)
+
+// lineEditor is a LineReader that decodes the arrow key escape sequences
+// in its input, letting the up and down arrows walk through the history of
+// the session. It does not put the terminal into raw mode, so a terminal in
+// its usual cooked mode only passes a line on once Enter is pressed: arrows
+// pressed while typing are applied then, and the recalled line is written
+// out after the prompt at that point. Recalling lines as the keys are
+// pressed needs a line editing library plugged in with WithLineReader
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *History
+	eof     bool
+}
+
+// newLineEditor returns a lineEditor reading from in, redrawing recalled
+// lines on out and recording entered lines in history
+func newLineEditor(in io.Reader, out io.Writer, history *History) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out, history: history}
+}
+
+// ReadLine returns the next line entered, after applying any history
+// recall it contains, and adds it to the history
+func (e *lineEditor) ReadLine() (string, error) {
+	if e.eof {
+		return "", io.EOF
+	}
+
+	var line []rune
+	var draft []rune                 // the line being typed before browsing the history
+	recalled := len(e.history.lines) // index of the recalled line, or len for none
+	redrawn := false                 // whether the line was written out
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			e.eof = true
+			if len(line) == 0 {
+				return "", err
+			}
+			break
+		}
+
+		if r == '\n' {
+			break
+		}
+		if r == '\r' {
+			if next, _ := e.in.Peek(1); len(next) == 1 && next[0] == '\n' {
+				e.in.ReadByte()
+			}
+			break
+		}
+		if r != escapePrefix {
+			line = append(line, r)
+			continue
+		}
+
+		switch e.readEscape() {
+		case keyUp, keyUpApp:
+			if recalled == 0 {
+				continue
+			}
+			if recalled == len(e.history.lines) {
+				draft = line
+			}
+			recalled--
+			line = []rune(e.history.lines[recalled])
+		case keyDown, keyDownApp:
+			if recalled == len(e.history.lines) {
+				continue
+			}
+			recalled++
+			if recalled == len(e.history.lines) {
+				line = draft
+			} else {
+				line = []rune(e.history.lines[recalled])
+			}
+		default:
+			continue
+		}
+		io.WriteString(e.out, "\r\x1b[K"+PROMPT+string(line))
+		redrawn = true
+	}
+
+	if redrawn {
+		io.WriteString(e.out, "\n")
+	}
+	e.history.Add(string(line))
+	return string(line), nil
+}
+
+// readEscape reads the rest of an escape sequence whose ESC has just been
+// read and returns the whole sequence. Sequences other than the arrow keys
+// are consumed so that they do not end up in the line
+func (e *lineEditor) readEscape() string {
+	seq := []byte{escapePrefix}
+
+	b, err := e.in.ReadByte()
+	if err != nil {
+		return string(seq)
+	}
+	seq = append(seq, b)
+	switch b {
+	case 'O':
+		// a single final character follows
+		if b, err := e.in.ReadByte(); err == nil {
+			seq = append(seq, b)
+		}
+	case '[':
+		// parameters until a final character in the range @ to ~
+		for {
+			b, err := e.in.ReadByte()
+			if err != nil {
+				break
+			}
+			seq = append(seq, b)
+			if b >= '@' && b <= '~' {
+				break
+			}
+		}
+	}
+	return string(seq)
+}
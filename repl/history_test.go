@@ -0,0 +1,140 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	h := NewHistory(3)
+	for _, line := range []string{"a", "", "  ", "b", "b", "c", "d"} {
+		h.Add(line)
+	}
+
+	want := []string{"b", "c", "d"}
+	if strings.Join(h.Lines(), ",") != strings.Join(want, ",") {
+		t.Errorf("wrong history. want=%q, got=%q", want, h.Lines())
+	}
+}
+
+func TestLineEditorRecall(t *testing.T) {
+	input := "first\n" +
+		"second\r\n" +
+		"\x1b[A\x1b[A\n" + // up twice recalls the first line
+		"draft\x1b[A\x1b[B\n" + // down past the newest line restores the draft
+		"\x1b[A\x1b[D\n" + // other escape sequences are ignored
+		"\x1bOA\x1bOA\x1bOA\x1bOA\x1bOA\n" + // up stops at the oldest line
+		"last"
+
+	var out bytes.Buffer
+	editor := newLineEditor(strings.NewReader(input), &out, NewHistory(DefaultHistorySize))
+
+	want := []string{"first", "second", "first", "draft", "draft", "first", "last"}
+	for i, expected := range want {
+		line, err := editor.ReadLine()
+		if err != nil {
+			t.Fatalf("line %d: unexpected error %s", i, err)
+		}
+		if line != expected {
+			t.Errorf("line %d wrong. want=%q, got=%q", i, expected, line)
+		}
+	}
+
+	if _, err := editor.ReadLine(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last line, got=%v", err)
+	}
+	if !strings.Contains(out.String(), "\r\x1b[K"+PROMPT+"first") {
+		t.Errorf("recalled line not redrawn. got=%q", out.String())
+	}
+}
+
+func TestStartRecallsHistory(t *testing.T) {
+	output := testRun("1 + 1\n\x1b[A\n")
+
+	if strings.Count(output, "2\n") != 2 {
+		t.Errorf("recalled line was not evaluated again. got=%q", output)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "monkey")
+	if err != nil {
+		t.Fatalf("could not create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".monkey_history")
+
+	var out bytes.Buffer
+	Start(strings.NewReader("1 + 1\n2 + 2\n3 + 3\n"), &out, Quiet(), HistoryFile(path, 2))
+
+	saved := LoadHistory(path, 10).Lines()
+	if strings.Join(saved, ",") != "2 + 2,3 + 3" {
+		t.Fatalf("wrong saved history. got=%q", saved)
+	}
+
+	// a new session recalls the oldest line kept by the previous one
+	out.Reset()
+	Start(strings.NewReader("\x1b[A\x1b[A\x1b[A\n"), &out, Quiet(), HistoryFile(path, 2))
+	if !strings.Contains(out.String(), "\n4\n") {
+		t.Errorf("history of the previous session not recalled. got=%q", out.String())
+	}
+}
+
+// historyChecker feeds a line to the REPL, then records what the history
+// file holds when the REPL asks for more input
+type historyChecker struct {
+	path  string
+	fed   bool
+	saved string
+}
+
+func (c *historyChecker) Read(p []byte) (int, error) {
+	if !c.fed {
+		c.fed = true
+		return copy(p, "1 + 1\n"), nil
+	}
+	data, _ := os.ReadFile(c.path)
+	c.saved = string(data)
+	return 0, io.EOF
+}
+
+func TestHistoryFileAppendsAsEntered(t *testing.T) {
+	dir, err := os.MkdirTemp("", "monkey")
+	if err != nil {
+		t.Fatalf("could not create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	checker := &historyChecker{path: filepath.Join(dir, ".monkey_history")}
+	var out bytes.Buffer
+	Start(checker, &out, Quiet(), HistoryFile(checker.path, 10))
+
+	if checker.saved != "1 + 1\n" {
+		t.Errorf("line not written when entered. got=%q", checker.saved)
+	}
+}
+
+func TestLoadHistoryToleratesBadFiles(t *testing.T) {
+	dir, err := os.MkdirTemp("", "monkey")
+	if err != nil {
+		t.Fatalf("could not create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if lines := LoadHistory(filepath.Join(dir, "missing"), 10).Lines(); len(lines) != 0 {
+		t.Errorf("history loaded from a missing file. got=%q", lines)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt")
+	if err := os.WriteFile(corrupt, []byte("1 + 1\n\xff\xfe\x00\n\x1b[A\nlen(\"a\")\r\n"), 0600); err != nil {
+		t.Fatalf("could not write history file: %s", err)
+	}
+	lines := LoadHistory(corrupt, 10).Lines()
+	if strings.Join(lines, ",") != `1 + 1,len("a")` {
+		t.Errorf("wrong lines loaded from a corrupt file. got=%q", lines)
+	}
+}
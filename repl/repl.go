@@ -4,51 +4,406 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"strings"
+	"time"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT is printed while reading the lines after one ending
+// with a backslash
+const CONTINUATION_PROMPT = ".. "
+
+// META_PREFIX marks a line as a REPL command rather than monkey code
+const META_PREFIX = ":"
+
+// BANNER is printed once when the REPL starts
+const BANNER = `  __  __             _
+ |  \/  | ___  _ __ | | _____ _   _
+ | |\/| |/ _ \| '_ \| |/ / _ \ | | |
+ | |  | | (_) | | | |   <  __/ |_| |
+ |_|  |_|\___/|_| |_|_|\_\___|\__, |
+                             |___/
+`
+
+// MONKEY_FACE is printed before the list of parser errors
+const MONKEY_FACE = `            __,__
+   .--.  .-"     "-.  .--.
+  / .. \/  .-. .-.  \/ .. \
+ | |  '|  /   Y   \  |'  | |
+ | \   \  \ 0 | 0 /  /   / |
+  \ '- ,\.-"""""""-./, -' /
+   ''-' /_   ^ ^   _\ '-''
+       |  \._   _./  |
+       \   \ '~' /   /
+        '._ '-=-' _.'
+           '-----'
+`
+
+// Option configures the REPL run by Start
+type Option func(*config)
+
+type config struct {
+	decorations bool       // print BANNER and MONKEY_FACE
+	lineReader  LineReader // source of the entered lines, nil to read them from in
+	historyFile string     // where the history is kept between sessions, if anywhere
+	historySize int        // number of lines of history remembered
+	color       *bool      // whether to color the output, nil to color it on terminals
+	engine      Engine     // what runs the entered code
+
+	inspectLimits object.InspectLimits // how much of large results to print
+}
+
+// Quiet stops the REPL from printing BANNER and MONKEY_FACE
+func Quiet() Option {
+	return func(c *config) {
+		c.decorations = false
+	}
+}
+
+// WithLineReader makes the REPL read its lines from r instead of decoding
+// them from its input, for example to plug in a line editing library
+func WithLineReader(r LineReader) Option {
+	return func(c *config) {
+		c.lineReader = r
+	}
+}
+
+// HistoryFile makes the REPL load the history of earlier sessions from
+// path when it starts and append each line to it as it is entered. The
+// file is trimmed to the last max lines when the REPL exits. It has no
+// effect when lines come from WithLineReader
+func HistoryFile(path string, max int) Option {
+	return func(c *config) {
+		c.historyFile = path
+		c.historySize = max
+	}
+}
+
+// NoColor stops the REPL from coloring its output
+func NoColor() Option {
+	return func(c *config) {
+		color := false
+		c.color = &color
+	}
+}
+
+// Color makes the REPL color its output even when it is not a terminal
+func Color() Option {
+	return func(c *config) {
+		color := true
+		c.color = &color
+	}
+}
+
+// WithInspectLimits bounds how much of large or deeply nested results the
+// REPL prints. By default it uses object.DefaultInspectLimits
+func WithInspectLimits(limits object.InspectLimits) Option {
+	return func(c *config) {
+		c.inspectLimits = limits
+	}
+}
+
+// Engine names a way of running monkey code
+type Engine string
+
+const (
+	// EngineEval walks the AST with the evaluator
+	EngineEval Engine = "eval"
+	// EngineVM compiles to bytecode and runs it on the virtual machine,
+	// which supports fewer constructs so far
+	EngineVM Engine = "vm"
+)
+
+// WithEngine makes the REPL run code with engine rather than the
+// evaluator. Both engines share the lexer, parser and macro expansion
+func WithEngine(engine Engine) Option {
+	return func(c *config) {
+		c.engine = engine
+	}
+}
+
 // Start takes an input and output, and initiates the main REPL loop.
-func Start(in io.Reader, out io.Writer) {
-	// Start a new scanner
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+func Start(in io.Reader, out io.Writer, opts ...Option) {
+	cfg := &config{
+		decorations:   true,
+		historySize:   DefaultHistorySize,
+		inspectLimits: object.DefaultInspectLimits,
+		engine:        EngineEval,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.decorations {
+		io.WriteString(out, BANNER)
+	}
+	paint := painter{enabled: isTerminal(out)}
+	if cfg.color != nil {
+		paint.enabled = *cfg.color
+	}
+
+	// Read lines from the input, applying any arrow keys in them to recall
+	// earlier ones
+	reader := cfg.lineReader
+	if reader == nil {
+		history := NewHistory(cfg.historySize)
+		if cfg.historyFile != "" {
+			history = LoadHistory(cfg.historyFile, cfg.historySize)
+			defer func() {
+				if err := history.Save(cfg.historyFile); err != nil {
+					fmt.Fprintf(out, "could not save history: %s\n", err)
+				}
+			}()
+			// append the lines as they are entered, so that they are kept
+			// even when the session is interrupted before it can save
+			if f, err := os.OpenFile(cfg.historyFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err == nil {
+				history.file = f
+				defer f.Close()
+			}
+		}
+		reader = newLineEditor(in, out, history)
+	}
+	s := newSession()
 
 	for {
 		// Print the prompt
 		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, readErr := readInput(out, reader)
+		if readErr != nil {
 			return
 		}
-		// Takes a string of bytes
-		line := scanner.Text()
+		if strings.HasPrefix(line, META_PREFIX) {
+			runMetaCommand(out, line, s, cfg, paint)
+			continue
+		}
 		// Start a new lexer with said string
 		l := lexer.New(line)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(l.Errors()) != 0 || len(p.Errors()) != 0 {
+			if cfg.decorations {
+				io.WriteString(out, MONKEY_FACE)
+				io.WriteString(out, "Woops! We ran into some monkey business here!\n")
+			}
+			// lexical problems explain any parser errors they lead to
+			if len(l.Errors()) != 0 {
+				printLexerErrors(out, paint, l.Errors())
+			} else {
+				printParserErrors(out, paint, line, p.ErrorsDetailed())
+			}
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
+
+		evaluated := s.eval(program, cfg.engine)
 		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
+			io.WriteString(out, paintResult(paint, evaluated, cfg.inspectLimits))
 			io.WriteString(out, "\n")
 		}
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// readInput returns the next line from reader, joined with the lines after
+// it for as long as they end with a backslash. The backslashes are kept, as
+// the lexer reads them as whitespace
+func readInput(out io.Writer, reader LineReader) (string, error) {
+	line, err := reader.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	for strings.HasSuffix(line, "\\") {
+		fmt.Fprintf(out, CONTINUATION_PROMPT)
+		next, err := reader.ReadLine()
+		if err != nil {
+			break
+		}
+		line += "\n" + next
+	}
+	return line, nil
+}
+
+// paintResult returns the printable form of an evaluated result within
+// limits: errors in the error color, strings as a whole and anything else
+// highlighted as source
+func paintResult(paint painter, obj object.Object, limits object.InspectLimits) string {
+	switch obj := obj.(type) {
+	case *object.Error:
+		return paint.error(obj.Inspect())
+	case *object.String:
+		return paint.string(obj.Inspect())
+	default:
+		return paint.highlight(object.InspectLimited(obj, limits))
+	}
+}
+
+// printLexerErrors prints each lexer error on its own line
+func printLexerErrors(out io.Writer, paint painter, errors []string) {
 	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+		io.WriteString(out, "\t"+paint.error(msg)+"\n")
+	}
+}
+
+// printParserErrors prints each error followed by the source line it
+// refers to, with a caret under the offending token
+func printParserErrors(out io.Writer, paint painter, source string, errors []parser.ParserError) {
+	lines := strings.Split(source, "\n")
+
+	for _, err := range errors {
+		io.WriteString(out, "\t"+paint.error(err.Message)+"\n")
+
+		pos := err.Token.Pos
+		if pos.Line < 1 || pos.Line > len(lines) {
+			continue
+		}
+		io.WriteString(out, "\t"+paint.highlight(lines[pos.Line-1])+"\n")
+		io.WriteString(out, "\t"+caret(lines[pos.Line-1], pos.Column)+"\n")
+	}
+}
+
+// caret returns a line pointing at the given column of src. Tabs in src
+// are kept so the caret lines up however wide they are displayed
+func caret(src string, column int) string {
+	var out strings.Builder
+	for i, r := range []rune(src) {
+		if i >= column-1 {
+			break
+		}
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	for i := len([]rune(src)); i < column-1; i++ {
+		out.WriteRune(' ')
+	}
+	out.WriteString("^")
+	return out.String()
+}
+
+// runMetaCommand executes a REPL command such as :env, :reset,
+// :type <expr>, :ast <code>, :time <code>, :save <path> or :load <path>
+func runMetaCommand(out io.Writer, line string, s *session, cfg *config, paint painter) {
+	command := strings.TrimSpace(strings.TrimPrefix(line, META_PREFIX))
+	name, arg := command, ""
+	if i := strings.IndexAny(command, " \t"); i >= 0 {
+		name, arg = command[:i], strings.TrimSpace(command[i+1:])
+	}
+
+	switch name {
+	case "env":
+		printEnvironment(out, s.env)
+	case "reset":
+		// builtins are not bound in the environment, so nothing needs seeding
+		*s = *newSession()
+	case "type":
+		printType(out, arg, s.env)
+	case "ast":
+		printAST(out, arg)
+	case "time":
+		timeEval(out, arg, s, cfg, paint)
+	case "save":
+		s.save(out, arg)
+	case "load":
+		s.load(out, arg)
+	default:
+		io.WriteString(out, "unknown command: "+line+"\n")
+	}
+}
+
+// parseSource parses the source given to a command, printing the lexer
+// or else parser errors when it does not parse
+func parseSource(out io.Writer, src string) (*ast.Program, bool) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	switch {
+	case len(l.Errors()) != 0:
+		printLexerErrors(out, painter{}, l.Errors())
+		return nil, false
+	case len(p.Errors()) != 0:
+		printParserErrors(out, painter{}, src, p.ErrorsDetailed())
+		return nil, false
 	}
+	return program, true
+}
+
+// printAST parses src and prints its syntax tree, without evaluating it
+func printAST(out io.Writer, src string) {
+	if program, ok := parseSource(out, src); ok {
+		io.WriteString(out, ast.PrettyPrint(program))
+	}
+}
+
+// timeEval runs src in the session like any entered line, with the
+// engine, colors and inspect limits of cfg and paint, then prints how long
+// it took followed by the result
+func timeEval(out io.Writer, src string, s *session, cfg *config, paint painter) {
+	program, ok := parseSource(out, src)
+	if !ok {
+		return
+	}
+
+	start := time.Now()
+	evaluated := s.eval(program, cfg.engine)
+	elapsed := time.Since(start)
+
+	fmt.Fprintf(out, "time: %s\n", elapsed)
+	if evaluated != nil {
+		io.WriteString(out, paintResult(paint, evaluated, cfg.inspectLimits)+"\n")
+	}
+}
+
+// printType evaluates the expression src in env and prints the type of
+// its value rather than the value itself
+func printType(out io.Writer, src string, env *object.Environment) {
+	l := lexer.New(src)
+	exp, errors := parser.New(l).ParseExpression()
+	if len(l.Errors()) != 0 {
+		errors = l.Errors()
+	}
+	if len(errors) != 0 {
+		for _, msg := range errors {
+			io.WriteString(out, "\t"+msg+"\n")
+		}
+		return
+	}
+
+	evaluated := evaluator.Eval(exp, env)
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, err.Inspect()+"\n")
+		return
+	}
+	if evaluated == nil {
+		evaluated = evaluator.NULL
+	}
+	io.WriteString(out, string(evaluated.Type())+"\n")
+}
+
+// printEnvironment prints every binding of env alongside its value.
+// Functions are shown by their signature only.
+func printEnvironment(out io.Writer, env *object.Environment) {
+	for _, name := range env.Keys() {
+		val, _ := env.Get(name)
+		fmt.Fprintf(out, "%s = %s\n", name, inspectBinding(val))
+	}
+}
+
+// inspectBinding returns the printable form of a bound value
+func inspectBinding(val object.Object) string {
+	fn, ok := val.(*object.Function)
+	if !ok {
+		return val.Inspect()
+	}
+
+	return "fn(" + strings.Join(ast.ParameterList(fn.Parameters, fn.Defaults), ", ") + ")"
 }
@@ -0,0 +1,260 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/object"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func testRun(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out, Quiet())
+	return out.String()
+}
+
+func TestEnvCommand(t *testing.T) {
+	output := testRun("let x = 5; let y = fn(a){a};\n:env\n")
+
+	for _, want := range []string{"x = 5", "y = fn(a)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q. got=%q", want, output)
+		}
+	}
+}
+
+func TestMacroExpansion(t *testing.T) {
+	output := testRun("let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };\n" +
+		"unless(1 > 2, 10, 20)\n")
+
+	if !strings.Contains(output, ">> 10\n") {
+		t.Errorf("output does not contain expanded result. got=%q", output)
+	}
+}
+
+func TestParserErrorCaret(t *testing.T) {
+	output := testRun("let 5;\n")
+
+	want := "\tlet 5;\n" +
+		"\t    ^\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("output does not contain %q. got=%q", want, output)
+	}
+}
+
+func TestDecorations(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("let 5;\n"), &out)
+	output := out.String()
+
+	if !strings.HasPrefix(output, BANNER) {
+		t.Errorf("output does not start with the banner. got=%q", output)
+	}
+	if !strings.Contains(output, MONKEY_FACE) {
+		t.Errorf("output does not contain the monkey face. got=%q", output)
+	}
+
+	output = testRun("let 5;\n")
+	if strings.Contains(output, BANNER) || strings.Contains(output, MONKEY_FACE) {
+		t.Errorf("quiet output contains decorations. got=%q", output)
+	}
+}
+
+func TestErrorPositionOutput(t *testing.T) {
+	output := testRun("let x = 1; foobar\n")
+
+	want := "ERROR: 1:12: identifier not found: foobar"
+	if !strings.Contains(output, want) {
+		t.Errorf("output does not contain %q. got=%q", want, output)
+	}
+}
+
+func TestLexerErrorOutput(t *testing.T) {
+	output := testRun("let s = \"abc\n5 $ 3\n")
+
+	for _, want := range []string{"\t1:9: unterminated string\n", "\t1:3: illegal character '$'\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q. got=%q", want, output)
+		}
+	}
+	if strings.Contains(output, "no prefix parse function") {
+		t.Errorf("parser errors printed along with lexer errors. got=%q", output)
+	}
+}
+
+func TestInspectLimits(t *testing.T) {
+	var out bytes.Buffer
+	limits := object.InspectLimits{MaxDepth: 2, MaxElements: 2}
+	Start(strings.NewReader("[1, [2, [3]], 4]\n"), &out, Quiet(), WithInspectLimits(limits))
+
+	if !strings.Contains(out.String(), "[1, [2, [...]], ...]\n") {
+		t.Errorf("result not truncated. got=%q", out.String())
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":type 1 + 2\n", "INTEGER\n"},
+		{"let s = \"a\";\n:type s\n", "STRING\n"},
+		{":type fn(x) { x }\n", "FUNCTION\n"},
+		{":type [1][5]\n", "NULL\n"},
+		{":type 1 + true\n", "ERROR: 1:1: type mismatch: INTEGER + BOOLEAN\n"},
+		{":type 1 +\n", "\tno prefix parse function for EOF found\n"},
+		{":type 1 +\n1 + 1\n", "2\n"},
+	}
+
+	for _, tt := range tests {
+		output := testRun(tt.input)
+		if !strings.Contains(output, tt.expected) {
+			t.Errorf("output for %q does not contain %q. got=%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestASTCommand(t *testing.T) {
+	output := testRun(":ast 1 + 2 * 3\n")
+
+	want := "Program\n" +
+		"  ExpressionStatement\n" +
+		"    InfixExpression +\n" +
+		"      IntegerLiteral 1\n" +
+		"      InfixExpression *\n" +
+		"        IntegerLiteral 2\n" +
+		"        IntegerLiteral 3\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("output does not contain %q. got=%q", want, output)
+	}
+
+	output = testRun(":ast let 5;\nx\n")
+	for _, want := range []string{"\texpected next token to be IDENT, got INT instead\n", "\tlet 5;\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q. got=%q", want, output)
+		}
+	}
+	if strings.Contains(output, "Program") {
+		t.Errorf("tree printed despite parser errors. got=%q", output)
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	output := testRun(":time let x = 40; x + 2\nx\n")
+
+	if !regexp.MustCompile(`time: [0-9.]+(ns|µs|ms|s)\n42\n`).MatchString(output) {
+		t.Errorf("output does not contain a duration followed by the result. got=%q", output)
+	}
+	if !strings.HasSuffix(output, ">> 40\n>> ") {
+		t.Errorf("bindings made under :time were not kept. got=%q", output)
+	}
+}
+
+func TestTimeCommandUsesSessionSettings(t *testing.T) {
+	var out bytes.Buffer
+	limits := object.InspectLimits{MaxDepth: 2, MaxElements: 2}
+	Start(strings.NewReader(":time [1, [2, [3]], 4]\n"), &out, Quiet(), WithInspectLimits(limits))
+	if !strings.Contains(out.String(), "\n[1, [2, [...]], ...]\n") {
+		t.Errorf("result not truncated. got=%q", out.String())
+	}
+
+	out.Reset()
+	Start(strings.NewReader(":time 5 % 2\n"), &out, Quiet(), WithEngine(EngineVM))
+	if !strings.Contains(out.String(), "\nERROR: 1:1: unknown operator %\n") {
+		t.Errorf("not run by the virtual machine. got=%q", out.String())
+	}
+
+	out.Reset()
+	Start(strings.NewReader(":time 1\n"), &out, Quiet(), Color())
+	if !strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("result not colored. got=%q", out.String())
+	}
+}
+
+func TestResetCommand(t *testing.T) {
+	output := testRun("let x = 5;\nlet m = macro() { quote(1) };\nx\n:reset\nx\nm()\n:env\nlet x = 6;\nx\n")
+
+	want := ">> >> >> 5\n" +
+		">> >> ERROR: 1:1: identifier not found: x\n" +
+		">> ERROR: 1:1: identifier not found: m\n" +
+		">> >> >> 6\n>> "
+	if output != want {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", want, output)
+	}
+}
+
+func TestEngines(t *testing.T) {
+	input := "1 + 2\n2 * (3 + 4) / 7\nif (1 > 2) { 10 }\nif (5 == 5) { 1 < 2 } else { 3 }\n10 / 0\n"
+
+	var eval, compiled bytes.Buffer
+	Start(strings.NewReader(input), &eval, Quiet(), WithEngine(EngineEval))
+	Start(strings.NewReader(input), &compiled, Quiet(), WithEngine(EngineVM))
+
+	want := ">> 3\n>> 2\n>> null\n>> true\n>> ERROR: "
+	if !strings.HasPrefix(eval.String(), want) {
+		t.Errorf("wrong output from the evaluator. want prefix=%q, got=%q", want, eval.String())
+	}
+	if !strings.HasPrefix(compiled.String(), want) {
+		t.Errorf("wrong output from the VM. want prefix=%q, got=%q", want, compiled.String())
+	}
+
+	output := testRun("let x = 1;\nx\n")
+	if !strings.HasSuffix(output, ">> 1\n>> ") {
+		t.Errorf("the evaluator is not the default engine. got=%q", output)
+	}
+}
+
+func TestEnginesKeepBindings(t *testing.T) {
+	input := "let x = 5;\nx\nlet y = x * 2; y + 1\nlet z = x + y;\nif (z > 10) { z } else { y }\n"
+
+	var eval, compiled bytes.Buffer
+	Start(strings.NewReader(input), &eval, Quiet(), WithEngine(EngineEval))
+	Start(strings.NewReader(input), &compiled, Quiet(), WithEngine(EngineVM))
+
+	want := ">> >> 5\n>> 11\n>> >> 15\n>> "
+	if eval.String() != want {
+		t.Errorf("wrong output from the evaluator.\nwant=%q\ngot=%q", want, eval.String())
+	}
+	if compiled.String() != want {
+		t.Errorf("wrong output from the VM.\nwant=%q\ngot=%q", want, compiled.String())
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	output := testRun("1 + \\\n2 * \\\n3\nlet x = 4\n")
+
+	want := ">> .. .. 7\n>> "
+	if !strings.HasPrefix(output, want) {
+		t.Errorf("continued lines not read as one input. want prefix=%q, got=%q", want, output)
+	}
+
+	output = testRun("1 +\n2\n")
+	if strings.Contains(output, "..") {
+		t.Errorf("line without a trailing backslash was continued. got=%q", output)
+	}
+}
+
+func TestEnginesAgreeAtIntegerLimits(t *testing.T) {
+	// only the evaluator knows where an error was raised
+	position := regexp.MustCompile(`ERROR: \d+:\d+: `)
+
+	for _, input := range []string{
+		"9223372036854775806 + 1",
+		"9223372036854775807 + 1",
+		"0 - 9223372036854775807 - 1",
+		"0 - 9223372036854775807 - 2",
+		"4611686018427387904 * 2",
+		"(0 - 4611686018427387904) * 2",
+		"(0 - 9223372036854775807 - 1) / (0 - 1)",
+	} {
+		var eval, compiled bytes.Buffer
+		Start(strings.NewReader(input+"\n"), &eval, Quiet(), WithEngine(EngineEval))
+		Start(strings.NewReader(input+"\n"), &compiled, Quiet(), WithEngine(EngineVM))
+
+		want := position.ReplaceAllString(eval.String(), "ERROR: ")
+		if compiled.String() != want {
+			t.Errorf("engines disagree on %q. evaluator=%q, vm=%q", input, want, compiled.String())
+		}
+	}
+}
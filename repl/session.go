@@ -0,0 +1,151 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/vm"
+	"os"
+	"strings"
+)
+
+// session holds the bindings made during a REPL session
+type session struct {
+	env      *object.Environment
+	macroEnv *object.Environment
+
+	// what the virtual machine keeps from one line to the next
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+func newSession() *session {
+	return &session{
+		env:         object.NewEnvironment(),
+		macroEnv:    object.NewEnvironment(),
+		symbolTable: compiler.NewSymbolTable(),
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalsSize),
+	}
+}
+
+// eval expands the macros of program and runs it with engine
+func (s *session) eval(program *ast.Program, engine Engine) object.Object {
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		return err
+	}
+	if engine == EngineVM {
+		return s.runVM(expanded.(*ast.Program))
+	}
+	return evaluator.Eval(expanded, s.env)
+}
+
+// runVM compiles program against the bindings of the session and runs it
+// on the virtual machine. Like the evaluator, it returns the value of the
+// last statement only when that is an expression, and failures as errors
+func (s *session) runVM(program *ast.Program) object.Object {
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	bytecode := comp.Bytecode()
+	s.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, s.globals)
+	if err := machine.Run(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	if n := len(program.Statements); n == 0 {
+		return nil
+	} else if _, ok := program.Statements[n-1].(*ast.ExpressionStatement); !ok {
+		return nil
+	}
+	return machine.LastPoppedStackElem()
+}
+
+// save writes the bindings of the session to path as let statements,
+// which load reads back. Bindings whose value cannot be written as
+// source, such as builtins or functions closing over local variables,
+// are left out and listed
+func (s *session) save(out io.Writer, path string) {
+	if path == "" {
+		io.WriteString(out, "usage: :save <path>\n")
+		return
+	}
+
+	var src strings.Builder
+	for _, name := range s.env.Keys() {
+		val, _ := s.env.Get(name)
+		if !s.hasSource(val) {
+			fmt.Fprintf(out, "not saved: %s (%s)\n", name, val.Type())
+			continue
+		}
+		keyword := "let"
+		if mutable, _ := s.env.IsMutable(name); !mutable {
+			keyword = "const"
+		}
+		fmt.Fprintf(&src, "%s %s = %s;\n", keyword, name, object.Source(val))
+	}
+
+	if err := os.WriteFile(path, []byte(src.String()), 0644); err != nil {
+		fmt.Fprintf(out, "could not save session: %s\n", err)
+	}
+}
+
+// hasSource reports whether object.Source renders val as source that
+// evaluates back to it in a session like this one
+func (s *session) hasSource(val object.Object) bool {
+	switch val := val.(type) {
+	case *object.Integer, *object.Boolean, *object.String:
+		return true
+	case *object.Function:
+		// bindings the function closes over, other than the session's
+		// own, would be lost
+		return val.Env == s.env
+	case *object.Array:
+		for _, el := range val.Elements {
+			if !s.hasSource(el) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		for _, pair := range val.Pairs {
+			if !s.hasSource(pair.Key) || !s.hasSource(pair.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// load evaluates the source at path in the session, printing any error
+func (s *session) load(out io.Writer, path string) {
+	if path == "" {
+		io.WriteString(out, "usage: :load <path>\n")
+		return
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "could not load session: %s\n", err)
+		return
+	}
+	program, ok := parseSource(out, string(src))
+	if !ok {
+		return
+	}
+	if err, ok := s.eval(program, EngineEval).(*object.Error); ok {
+		io.WriteString(out, err.Inspect()+"\n")
+	}
+}
@@ -0,0 +1,79 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.monkey")
+
+	output := testRun(strings.Join([]string{
+		`const x = 5;`,
+		`let s = "hi";`,
+		`let xs = [1, "a", [true]];`,
+		`let h = {"k": [x, s], 2: false};`,
+		`let add = fn(a, b = 2) { a + b + x };`,
+		`let twice = fn(a) { add(add(a)) };`,
+		`let l = len;`,
+		`let counter = fn() { let n = 1; fn() { n } }();`,
+		`:save ` + path,
+	}, "\n") + "\n")
+
+	for _, want := range []string{"not saved: counter (FUNCTION)\n", "not saved: l (BUILTIN)\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q. got=%q", want, output)
+		}
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read saved session: %s", err)
+	}
+	if !strings.Contains(string(saved), "let s = \"hi\";\n") {
+		t.Errorf("saved session does not bind s. got=%q", saved)
+	}
+	if !strings.Contains(string(saved), "const x = 5;\n") {
+		t.Errorf("saved session does not keep x constant. got=%q", saved)
+	}
+
+	// a fresh session gets the same values back
+	output = testRun(":load " + path + "\nx\ns\nxs\nh[\"k\"]\nh[2]\ntwice(1)\nl\n")
+	want := ">> >> 5\n>> hi\n>> [1, a, [true]]\n>> [5, hi]\n>> false\n>> 15\n" +
+		">> ERROR: 1:1: identifier not found: l\n>> "
+	if output != want {
+		t.Errorf("wrong output after loading.\nwant=%q\ngot=%q", want, output)
+	}
+}
+
+func TestSaveAndLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":save\n", "usage: :save <path>\n"},
+		{":load\n", "usage: :load <path>\n"},
+		{":load " + filepath.Join(dir, "missing") + "\n", "could not load session: "},
+		{":save " + filepath.Join(dir, "missing", "file") + "\n", "could not save session: "},
+	}
+
+	for _, tt := range tests {
+		output := testRun(tt.input)
+		if !strings.Contains(output, tt.expected) {
+			t.Errorf("output for %q does not contain %q. got=%q", tt.input, tt.expected, output)
+		}
+	}
+
+	path := filepath.Join(dir, "broken.monkey")
+	if err := os.WriteFile(path, []byte("let x = ;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := testRun(":load " + path + "\n")
+	if !strings.Contains(output, "\tno prefix parse function for ; found\n") {
+		t.Errorf("parser errors of the loaded file not shown. got=%q", output)
+	}
+}
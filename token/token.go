@@ -3,14 +3,21 @@
 // the different parts of the source code
 package token
 
+import "fmt"
+
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	// Whitespace, only emitted by lexers preserving it
+	WHITESPACE = "WHITESPACE"
+	NEWLINE    = "NEWLINE"
+
 	// Identifiers + Literals
 	IDENT  = "IDENT" // add, foobar, x, y...
 	INT    = "INT"
 	STRING = "STRING"
+	CHAR   = "CHAR" // 'a'
 
 	// Operators
 	ASSIGN   = "="
@@ -19,10 +26,18 @@ const (
 	MINUS    = "-"
 	SLASH    = "/"
 	ASTERISK = "*"
+	PERCENT  = "%"
+	TILDE    = "~"
+	QUESTION = "?"
 	LT       = "<"
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	INCR     = "++"
+	DECR     = "--"
+	PIPE     = "|>"
+	AND      = "&&"
+	OR       = "||"
 
 	// Delimiters
 	COMMA     = ","
@@ -34,26 +49,47 @@ const (
 	LBRACKET  = "["
 	RBRACKET  = "]"
 	COLON     = ":"
+	ELLIPSIS  = "..."
 
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	DO       = "DO"
+	FOR      = "FOR"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MACRO    = "MACRO"
+	SWITCH   = "SWITCH"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
 )
 
 // mapping keywords to token types
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"if":       IF,
+	"else":     ELSE,
+	"true":     TRUE,
+	"false":    FALSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"do":       DO,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
+	"switch":   SWITCH,
+	"case":     CASE,
+	"default":  DEFAULT,
 }
 
 // LookupIdent checks if the identifier is a monkey language keyword
@@ -69,5 +105,18 @@ type TokenType string
 
 type Token struct {
 	Type    TokenType
-	Literal string // the string that was identified as the token type
+	Literal string   // the string that was identified as the token type
+	Pos     Position // where the token starts in the source code
+}
+
+// Position is a location in the source code.
+// Lines and columns start at 1, columns count characters.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// String returns the position in line:column form
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
@@ -0,0 +1,495 @@
+// Package transpile translates monkey programs into Go source, so that hot
+// scripts can be compiled rather than interpreted.
+//
+// Only a subset of monkey has a translation: integer and boolean values,
+// arithmetic and comparisons, if/else, and functions bound by top-level
+// lets that take and return integers. Everything else is reported by an
+// UnsupportedError.
+package transpile
+
+import (
+	"fmt"
+	"go/format"
+	"monkey/ast"
+	"strconv"
+	"strings"
+)
+
+// goType is the Go type of a translated expression
+type goType int
+
+const (
+	invalid goType = iota // the expression has no translation
+	integer
+	boolean
+)
+
+func (t goType) String() string {
+	switch t {
+	case integer:
+		return "int64"
+	case boolean:
+		return "bool"
+	default:
+		return "invalid"
+	}
+}
+
+// UnsupportedError lists the parts of a program that could not be
+// translated, each prefixed with its position
+type UnsupportedError struct {
+	Problems []string
+}
+
+func (e *UnsupportedError) Error() string {
+	return "cannot transpile: " + strings.Join(e.Problems, "; ")
+}
+
+// names that cannot be used as they are in the generated source, because
+// they are Go keywords or are needed by the translation
+var reserved = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true,
+	"for": true, "func": true, "go": true, "goto": true, "if": true,
+	"import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true,
+	"switch": true, "type": true, "var": true,
+	"_": true, "bool": true, "fmt": true, "init": true, "int64": true, "main": true,
+}
+
+// goName returns the Go identifier standing for a monkey one
+func goName(name string) string {
+	if reserved[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// Transpile returns the source of a Go main package equivalent to program.
+// Top-level functions become Go functions, other top-level lets become
+// package variables, and main prints the value of each top-level
+// expression, as the REPL would.
+// Integers overflow and division by zero panics in the generated code,
+// where monkey reports an error
+func Transpile(program *ast.Program) (string, error) {
+	t := &transpiler{
+		funcs:   map[string]*ast.FunctionLiteral{},
+		globals: map[string]goType{},
+	}
+	decls := make([]string, len(program.Statements))
+	var main strings.Builder
+
+	// functions may call each other and use any global, so their
+	// signatures are known before anything is translated
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Name != nil {
+			if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+				if t.funcs[let.Name.Value] != nil {
+					t.problem(let, "identifier already defined: %s", let.Name.Value)
+				}
+				t.funcs[let.Name.Value] = fn
+			}
+		}
+	}
+
+	for i, stmt := range program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if stmt.Name == nil {
+				t.unsupported(stmt.Pattern)
+				continue
+			}
+			if _, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				continue
+			}
+			decls[i] = t.global(stmt)
+		case *ast.ExpressionStatement:
+			code, typ := t.expression(stmt.Expression)
+			if typ != invalid {
+				t.usesFmt = true
+				main.WriteString("fmt.Println(" + code + ")\n")
+			}
+		default:
+			t.unsupported(stmt)
+		}
+	}
+
+	for i, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Name != nil {
+			if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+				decls[i] = t.function(let.Name.Value, fn)
+			}
+		}
+	}
+
+	if len(t.problems) != 0 {
+		return "", &UnsupportedError{Problems: t.problems}
+	}
+
+	var out strings.Builder
+	out.WriteString("package main\n\n")
+	if t.usesFmt {
+		out.WriteString("import \"fmt\"\n\n")
+	}
+	for _, decl := range decls {
+		if decl != "" {
+			out.WriteString(decl + "\n")
+		}
+	}
+	out.WriteString("func main() {\n" + main.String() + "}\n")
+
+	src, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
+
+// transpiler holds the state of the translation of a program
+type transpiler struct {
+	funcs   map[string]*ast.FunctionLiteral // top-level functions, by name
+	globals map[string]goType               // top-level variables, by name
+	locals  []map[string]goType             // variables of the enclosing blocks, innermost last
+
+	result  *goType // type of the value of the function or if being translated
+	inValue bool    // translating an if used as a value, out of which return cannot jump
+
+	usesFmt  bool
+	problems []string
+}
+
+func (t *transpiler) problem(node ast.Node, format string, a ...interface{}) {
+	t.problems = append(t.problems, node.Pos().String()+": "+fmt.Sprintf(format, a...))
+}
+
+// unsupported reports a node that has no translation
+func (t *transpiler) unsupported(node ast.Node) {
+	t.problem(node, "%s is not supported", strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+}
+
+// global returns the declaration of a top-level variable
+func (t *transpiler) global(let *ast.LetStatement) string {
+	code, typ := t.expression(let.Value)
+	if _, ok := t.globals[let.Name.Value]; ok || t.funcs[let.Name.Value] != nil {
+		t.problem(let, "identifier already defined: %s", let.Name.Value)
+	}
+	t.globals[let.Name.Value] = typ
+	if typ == invalid {
+		return ""
+	}
+	return fmt.Sprintf("var %s %s = %s\n", goName(let.Name.Value), typ, code)
+}
+
+// function returns the declaration of a top-level function
+func (t *transpiler) function(name string, fn *ast.FunctionLiteral) string {
+	if len(fn.Defaults) != 0 {
+		t.problem(fn, "default parameter values are not supported")
+	}
+
+	params := make([]string, len(fn.Parameters))
+	scope := map[string]goType{}
+	for i, p := range fn.Parameters {
+		params[i] = goName(p.Value) + " int64"
+		scope[p.Value] = integer
+	}
+
+	result := integer
+	t.locals = []map[string]goType{scope}
+	t.result = &result
+	body := t.statements(fn.Body, true)
+	t.locals, t.result = nil, nil
+
+	return fmt.Sprintf("func %s(%s) int64 {\n%s}\n", goName(name), strings.Join(params, ", "), body)
+}
+
+// block returns the statements of b in a scope of their own. When
+// returning, the value of b is returned
+func (t *transpiler) block(b *ast.BlockStatement, returning bool) string {
+	t.locals = append(t.locals, map[string]goType{})
+	defer func() { t.locals = t.locals[:len(t.locals)-1] }()
+	return t.statements(b, returning)
+}
+
+// statements returns the statements of b in the current scope. When
+// returning, the value of b is returned
+func (t *transpiler) statements(b *ast.BlockStatement, returning bool) string {
+	if returning && len(b.Statements) == 0 {
+		t.problem(b, "block without a value")
+	}
+
+	var out strings.Builder
+	for i, stmt := range b.Statements {
+		out.WriteString(t.statement(stmt, returning && i == len(b.Statements)-1))
+	}
+	return out.String()
+}
+
+// statement returns the translation of stmt. When returning, stmt ends a
+// block whose value is returned
+func (t *transpiler) statement(stmt ast.Statement, returning bool) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if returning {
+			t.problem(stmt, "block without a value")
+		}
+		return t.local(stmt)
+	case *ast.ReturnStatement:
+		if t.inValue {
+			t.problem(stmt, "return inside an if used as a value is not supported")
+			return ""
+		}
+		code, typ := t.expression(stmt.ReturnValue)
+		t.checkResult(stmt.ReturnValue, typ)
+		return "return " + code + "\n"
+	case *ast.ExpressionStatement:
+		if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+			return t.ifStatement(ie, returning)
+		}
+		code, typ := t.expression(stmt.Expression)
+		switch {
+		case typ == invalid:
+			return ""
+		case returning:
+			t.checkResult(stmt.Expression, typ)
+			return "return " + code + "\n"
+		case isCall(stmt.Expression):
+			return code + "\n"
+		default:
+			return "_ = " + code + "\n"
+		}
+	case *ast.BlockStatement:
+		return "{\n" + t.block(stmt, returning) + "}\n"
+	default:
+		t.unsupported(stmt)
+		return ""
+	}
+}
+
+// local returns the declaration of a variable inside a function
+func (t *transpiler) local(let *ast.LetStatement) string {
+	if let.Name == nil {
+		t.unsupported(let.Pattern)
+		return ""
+	}
+	if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+		t.problem(let.Value, "nested functions are not supported")
+		return ""
+	}
+
+	code, typ := t.expression(let.Value)
+	scope := t.locals[len(t.locals)-1]
+	if _, ok := scope[let.Name.Value]; ok {
+		t.problem(let, "identifier already defined: %s", let.Name.Value)
+	}
+	scope[let.Name.Value] = typ
+	if typ == invalid {
+		return ""
+	}
+	name := goName(let.Name.Value)
+	return fmt.Sprintf("var %s %s = %s\n_ = %s\n", name, typ, code, name)
+}
+
+// checkResult reports values of the wrong type for the function or if
+// being translated. The first value of an if sets its type
+func (t *transpiler) checkResult(node ast.Node, typ goType) {
+	switch {
+	case typ == invalid:
+	case *t.result == invalid:
+		*t.result = typ
+	case *t.result != typ:
+		t.problem(node, "expected a value of type %s, got %s", *t.result, typ)
+	}
+}
+
+// ifStatement returns ie as a Go if statement. When returning, both
+// branches return their value
+func (t *transpiler) ifStatement(ie *ast.IfExpression, returning bool) string {
+	cond, _ := t.condition(ie.Condition)
+	out := "if " + cond + " {\n" + t.block(ie.Consequence, returning) + "}"
+	switch {
+	case ie.Alternative != nil:
+		out += " else {\n" + t.block(ie.Alternative, returning) + "}"
+	case returning:
+		t.problem(ie, "if without else used as a value")
+	}
+	return out + "\n"
+}
+
+// ifValue returns ie as a function literal called in place, for ifs used
+// as values within expressions
+func (t *transpiler) ifValue(ie *ast.IfExpression) (string, goType) {
+	if ie.Alternative == nil {
+		t.problem(ie, "if without else used as a value")
+		return "", invalid
+	}
+	cond, ok := t.condition(ie.Condition)
+
+	result := invalid
+	outerResult, outerInValue := t.result, t.inValue
+	t.result, t.inValue = &result, true
+	consequence := t.block(ie.Consequence, true)
+	alternative := t.block(ie.Alternative, true)
+	t.result, t.inValue = outerResult, outerInValue
+
+	if !ok || result == invalid {
+		return "", invalid
+	}
+	return fmt.Sprintf("func() %s {\nif %s {\n%s} else {\n%s}\n}()", result, cond, consequence, alternative), result
+}
+
+// condition returns the translation of the condition of an if, which
+// must be a boolean
+func (t *transpiler) condition(exp ast.Expression) (string, bool) {
+	code, typ := t.expression(exp)
+	if typ == integer {
+		t.problem(exp, "condition must be a bool, got int64")
+		return "", false
+	}
+	return code, typ == boolean
+}
+
+// expression returns the translation of exp along with its type
+func (t *transpiler) expression(exp ast.Expression) (string, goType) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(exp.Value, 10), integer
+	case *ast.Boolean:
+		return strconv.FormatBool(exp.Value), boolean
+	case *ast.Identifier:
+		return t.identifier(exp)
+	case *ast.PrefixExpression:
+		return t.prefix(exp)
+	case *ast.InfixExpression:
+		return t.infix(exp)
+	case *ast.IfExpression:
+		return t.ifValue(exp)
+	case *ast.CallExpression:
+		return t.call(exp)
+	default:
+		t.unsupported(exp)
+		return "", invalid
+	}
+}
+
+// lookup returns the type of the variable bound to name, if any
+func (t *transpiler) lookup(name string) (goType, bool) {
+	for i := len(t.locals) - 1; i >= 0; i-- {
+		if typ, ok := t.locals[i][name]; ok {
+			return typ, true
+		}
+	}
+	typ, ok := t.globals[name]
+	return typ, ok
+}
+
+func (t *transpiler) identifier(ident *ast.Identifier) (string, goType) {
+	if typ, ok := t.lookup(ident.Value); ok {
+		return goName(ident.Value), typ
+	}
+	if t.funcs[ident.Value] != nil {
+		t.problem(ident, "functions used as values are not supported: %s", ident.Value)
+		return "", invalid
+	}
+	t.problem(ident, "identifier not found: %s", ident.Value)
+	return "", invalid
+}
+
+// operand returns the translation of exp as the operand of an operator,
+// parenthesised unless it is atomic. Go orders comparisons differently
+// from monkey, so nested operations are always grouped explicitly
+func (t *transpiler) operand(exp ast.Expression) (string, goType) {
+	code, typ := t.expression(exp)
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.Identifier, *ast.CallExpression:
+		return code, typ
+	default:
+		return "(" + code + ")", typ
+	}
+}
+
+func (t *transpiler) prefix(pe *ast.PrefixExpression) (string, goType) {
+	right, typ := t.operand(pe.Right)
+	if typ == invalid {
+		return "", invalid
+	}
+
+	switch {
+	case pe.Operator == "-" && typ == integer:
+		return "-" + right, integer
+	case pe.Operator == "~" && typ == integer:
+		return "^" + right, integer
+	case pe.Operator == "!" && typ == boolean:
+		return "!" + right, boolean
+	default:
+		t.problem(pe, "unsupported operator: %s%s", pe.Operator, typ)
+		return "", invalid
+	}
+}
+
+func (t *transpiler) infix(ie *ast.InfixExpression) (string, goType) {
+	left, leftType := t.operand(ie.Left)
+	right, rightType := t.operand(ie.Right)
+	if leftType == invalid || rightType == invalid {
+		return "", invalid
+	}
+
+	code := left + " " + ie.Operator + " " + right
+	switch ie.Operator {
+	case "+", "-", "*", "/", "%":
+		if leftType == integer && rightType == integer {
+			return code, integer
+		}
+	case "<", ">":
+		if leftType == integer && rightType == integer {
+			return code, boolean
+		}
+	case "==", "!=":
+		if leftType == rightType {
+			return code, boolean
+		}
+	case "&&", "||":
+		if leftType == boolean && rightType == boolean {
+			return code, boolean
+		}
+	}
+	t.problem(ie, "unsupported operator: %s %s %s", leftType, ie.Operator, rightType)
+	return "", invalid
+}
+
+func (t *transpiler) call(ce *ast.CallExpression) (string, goType) {
+	ident, ok := ce.Function.(*ast.Identifier)
+	if !ok {
+		t.problem(ce, "only functions bound by a top-level let can be called")
+		return "", invalid
+	}
+	fn := t.funcs[ident.Value]
+	if _, shadowed := t.lookup(ident.Value); shadowed || fn == nil {
+		t.problem(ce, "only functions bound by a top-level let can be called: %s", ident.Value)
+		return "", invalid
+	}
+	if len(ce.Arguments) != len(fn.Parameters) {
+		t.problem(ce, "wrong number of arguments to %s: expected %d, got %d",
+			ident.Value, len(fn.Parameters), len(ce.Arguments))
+		return "", invalid
+	}
+
+	args := make([]string, len(ce.Arguments))
+	valid := true
+	for i, arg := range ce.Arguments {
+		code, typ := t.expression(arg)
+		if typ == boolean {
+			t.problem(arg, "argument to %s must be an int64, got bool", ident.Value)
+		}
+		args[i] = code
+		valid = valid && typ == integer
+	}
+	if !valid {
+		return "", invalid
+	}
+	return goName(ident.Value) + "(" + strings.Join(args, ", ") + ")", integer
+}
+
+func isCall(exp ast.Expression) bool {
+	_, ok := exp.(*ast.CallExpression)
+	return ok
+}
@@ -0,0 +1,135 @@
+package transpile_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"monkey/lexer"
+	monkeyparser "monkey/parser"
+	"monkey/transpile"
+	"strings"
+	"testing"
+)
+
+func testTranspile(t *testing.T, input string) (string, error) {
+	t.Helper()
+	p := monkeyparser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return transpile.Transpile(program)
+}
+
+// typeCheck fails the test unless src is a well typed Go program
+func typeCheck(t *testing.T, src string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated source does not parse: %s\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("main", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated source does not type check: %s\n%s", err, src)
+	}
+}
+
+func TestTranspileFunction(t *testing.T) {
+	src, err := testTranspile(t, "let add = fn(a,b){a+b};")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := "func add(a int64, b int64) int64 {\n\treturn a + b\n}\n"
+	if !strings.Contains(src, want) {
+		t.Errorf("generated source does not contain %q. got=\n%s", want, src)
+	}
+	typeCheck(t, src)
+}
+
+func TestTranspileProgram(t *testing.T) {
+	input := `
+		let limit = 10;
+		let fib = fn(n) {
+			if (n < 2) { return n; }
+			fib(n - 1) + fib(n - 2)
+		};
+		let clamp = fn(x) {
+			let over = x > limit;
+			if (over) { limit } else { -x * ~0 }
+		};
+		let sign = fn(x) { if (x == 0) { 0 } else { x / (if (x > 0) { x } else { -x }) } };
+		fib(limit);
+		clamp(5) == 5 != !true;
+		1 < 2 == 3 > 4;
+		limit > 5 && !(limit > 20) || false;
+	`
+	src, err := testTranspile(t, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, want := range []string{
+		"var limit int64 = 10\n",
+		"func fib(n int64) int64 {\n\tif n < 2 {\n\t\treturn n\n\t}\n\treturn fib(n-1) + fib(n-2)\n}\n",
+		"\tvar over bool = x > limit\n",
+		"\tfmt.Println(fib(limit))\n",
+		"\tfmt.Println((clamp(5) == 5) != (!true))\n",
+		// monkey compares before testing equality, Go does not
+		"\tfmt.Println((1 < 2) == (3 > 4))\n",
+		"\tfmt.Println(((limit > 5) && (!(limit > 20))) || false)\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated source does not contain %q. got=\n%s", want, src)
+		}
+	}
+	typeCheck(t, src)
+}
+
+func TestTranspileUnsupported(t *testing.T) {
+	tests := []struct {
+		input    string
+		problems []string
+	}{
+		{
+			`let s = "a"; while (true) { 1 }`,
+			[]string{"1:9: StringLiteral is not supported", "1:14: WhileExpression is not supported"},
+		},
+		{
+			"let f = fn(x) { x }; f(true); f; len(1)",
+			[]string{
+				"1:24: argument to f must be an int64, got bool",
+				"1:31: functions used as values are not supported: f",
+				"1:34: only functions bound by a top-level let can be called: len",
+			},
+		},
+		{
+			"let f = fn(x) { if (x) { 1 } }; 1 + true; let [a] = b;",
+			[]string{
+				"1:33: unsupported operator: int64 + bool",
+				"1:47: ArrayPattern is not supported",
+				"1:21: condition must be a bool, got int64",
+				"1:17: if without else used as a value",
+			},
+		},
+		{
+			"let f = fn(x) { let y = if (x > 0) { return 1; } else { 2 }; y }",
+			[]string{"1:38: return inside an if used as a value is not supported"},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := testTranspile(t, tt.input)
+		unsupported, ok := err.(*transpile.UnsupportedError)
+		if !ok {
+			t.Errorf("error is not *transpile.UnsupportedError for %q. got=%T (%v)", tt.input, err, err)
+			continue
+		}
+		if strings.Join(unsupported.Problems, "\n") != strings.Join(tt.problems, "\n") {
+			t.Errorf("wrong problems for %q.\nwant=%q\ngot=%q", tt.input, tt.problems, unsupported.Problems)
+		}
+	}
+}
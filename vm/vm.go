@@ -0,0 +1,242 @@
+// Package vm runs the bytecode produced by the compiler on a stack
+// machine, as a faster alternative to walking the AST.
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+)
+
+// StackSize is the maximum number of values on the stack
+const StackSize = 2048
+
+// GlobalsSize is the maximum number of globals a program can bind, the
+// most a two byte operand can index
+const GlobalsSize = 65536
+
+// initialise common objects once
+var (
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+	Null  = &object.Null{}
+)
+
+// VM executes the instructions of a Bytecode
+type VM struct {
+	constants    []object.Object
+	instructions code.Instructions
+
+	globals []object.Object
+
+	stack []object.Object
+	sp    int // points to the next free slot; the top of the stack is stack[sp-1]
+}
+
+// New returns a VM ready to run bytecode
+func New(bytecode *compiler.Bytecode) *VM {
+	return &VM{
+		instructions: bytecode.Instructions,
+		constants:    bytecode.Constants,
+
+		globals: make([]object.Object, GlobalsSize),
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+	}
+}
+
+// NewWithGlobalsStore returns a VM ready to run bytecode with s as its
+// globals, so that the values bound by earlier runs remain available
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// LastPoppedStackElem returns the value most recently popped off the
+// stack, which is the value of the last expression statement run
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Run executes the instructions, stopping at the first runtime error
+func (vm *VM) Run() error {
+	for ip := 0; ip < len(vm.instructions); ip++ {
+		op := code.Opcode(vm.instructions[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+			if err := vm.executeComparison(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
+			// the loop increments ip past the target otherwise
+			ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(vm.instructions[ip+1:]))
+			ip += 2
+
+			if !isTruthy(vm.pop()) {
+				ip = pos - 1
+			}
+
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(vm.instructions[ip+1:])
+			ip += 2
+
+			// a global whose binding failed at run time was never set
+			global := vm.globals[globalIndex]
+			if global == nil {
+				return fmt.Errorf("variable used before it was bound")
+			}
+			if err := vm.push(global); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("opcode %d undefined", op)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	if left.Type() != object.INTEGER_OBJ || right.Type() != object.INTEGER_OBJ {
+		return fmt.Errorf("unsupported types for binary operation: %s %s", left.Type(), right.Type())
+	}
+
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	var operator string
+	switch op {
+	case code.OpAdd:
+		operator = "+"
+	case code.OpSub:
+		operator = "-"
+	case code.OpMul:
+		operator = "*"
+	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		operator = "/"
+	}
+
+	// overflow is an error, as it is for the evaluator
+	result, ok := object.CheckedArithmetic(operator, leftValue, rightValue)
+	if !ok {
+		return fmt.Errorf("integer overflow: %d %s %d", leftValue, operator, rightValue)
+	}
+	return vm.push(&object.Integer{Value: result})
+}
+
+func (vm *VM) executeComparison(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+		leftValue := left.(*object.Integer).Value
+		rightValue := right.(*object.Integer).Value
+
+		switch op {
+		case code.OpEqual:
+			return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+		case code.OpNotEqual:
+			return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+		default:
+			return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+		}
+	}
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(object.Equals(left, right)))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(!object.Equals(left, right)))
+	default:
+		return fmt.Errorf("unknown operator: %s > %s", left.Type(), right.Type())
+	}
+}
+
+// isTruthy follows the evaluator: only false and null are falsey
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
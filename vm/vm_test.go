@@ -0,0 +1,197 @@
+package vm
+
+import (
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"2", 2},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"2 * (3 + 4)", 14},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"0 - 9223372036854775807 - 1", -9223372036854775808},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"1 == true", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 } ", 20},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 }", Null},
+		{"if (false) { 10 }", Null},
+		{"if (true) { }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { 1; 2 } + 3", 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let x = 1; let x = x + 1; x", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalsStore(t *testing.T) {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+
+	run := func(input string) (object.Object, error) {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		constants = comp.Bytecode().Constants
+
+		vm := NewWithGlobalsStore(comp.Bytecode(), globals)
+		err := vm.Run()
+		return vm.LastPoppedStackElem(), err
+	}
+
+	run("let x = 40;")
+	result, err := run("x + 2")
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, "x + 2", 42, result)
+
+	// a binding that failed leaves its global unset
+	run("let y = 1 / 0;")
+	if _, err := run("y"); err == nil || err.Error() != "variable used before it was bound" {
+		t.Errorf("wrong error for a global that was never set. got=%v", err)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "division by zero"},
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"0 - 9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"(0 - 9223372036854775807 - 1) / (0 - 1)", "integer overflow: -9223372036854775808 / -1"},
+		{"1 + true", "unsupported types for binary operation: INTEGER BOOLEAN"},
+		{"true > false", "unknown operator: BOOLEAN > BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run()
+		if err == nil {
+			t.Errorf("no error running %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok {
+			t.Errorf("%s: object is not Integer. got=%T (%+v)", input, actual, actual)
+			return
+		}
+		if result.Value != int64(expected) {
+			t.Errorf("%s: object has wrong value. got=%d, want=%d", input, result.Value, expected)
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%s: object is not Null. got=%T (%+v)", input, actual, actual)
+		}
+	case bool:
+		result, ok := actual.(*object.Boolean)
+		if !ok {
+			t.Errorf("%s: object is not Boolean. got=%T (%+v)", input, actual, actual)
+			return
+		}
+		if result.Value != expected {
+			t.Errorf("%s: object has wrong value. got=%t, want=%t", input, result.Value, expected)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}